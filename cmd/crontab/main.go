@@ -0,0 +1,27 @@
+// Go crontab implementation using ticker
+//
+package main
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+func workProcess() {
+    fmt.Printf("[%v] Working ... ...\n", time.Now())
+}
+
+func main() {
+    n := 10                 // interval in minutes, 0 < n <= 60
+    started, worker := schedule.Crontab(n, workProcess)
+    ts := <-started         // trigger first launch at xx:[0-5]0
+    fmt.Printf("[%v] First working process start at %v.\n", time.Now(), ts)
+    for {
+        select {
+        case <-worker:      // the main go routine wait on worker, won't exit
+            fmt.Printf("[%v] Work complete!\n", time.Now())
+        }
+    }
+}
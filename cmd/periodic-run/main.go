@@ -0,0 +1,156 @@
+// Run like crontab:
+//    Start at hhmmss and run by interval, or
+//    run at the times of a cron expression
+//
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "os/signal"
+    "runtime"
+    "syscall"
+    "time"
+
+    "github.com/xzturn/go-by-example/periodic"
+    "github.com/xzturn/go-by-example/sigworker"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var configFile  *string = flag.String("c", ``, "config file which specify all params")
+var startTime   *string = flag.String("s", ``, "specify start time: ${hh:mm:ss}")
+var intervalSec *int = flag.Int("i", -1, "intervals in seconds, should > 0")
+var cronSpec    *string = flag.String("e", ``, "cron expression, e.g. \"*/10 * * * *\" or \"@daily\", overrides -s and -i")
+var checkOnly   *bool = flag.Bool("check", false, "validate the config and exit")
+var previewN    *int = flag.Int("preview", 0, "print the next N fire times of each job, run nothing and exit")
+var previewTz   *string = flag.String("tz", ``, "the timezone of -preview, e.g. Europe/Paris, the one of each job if empty")
+var minGap      *int = flag.Int("min-gap", 0, "with -preview, flag the jobs firing more often than every N seconds")
+var runOnce     *string = flag.String("once", ``, "run the named job once now and exit with its status, \"_\" for the unnamed top level job")
+var runNow      *bool = flag.Bool("now", false, "run each job now, then on its schedule")
+var httpAddr    *string = flag.String("http", ``, "serve the status and control api on ${host:port}, e.g. localhost:8080")
+
+////////////////////////////////////////////////////////////////////////////////
+// package init & main
+////////////////////////////////////////////////////////////////////////////////
+
+func init() {
+    runtime.GOMAXPROCS(runtime.NumCPU())
+}
+
+// exit status
+const (
+    exitOk      = 0 // a valid config, or all the runs in flight completed in the grace period
+    exitFlagged = 1 // a preview flagged some schedules, or a -once run failed without an exit code
+    exitConfig  = 2 // an invalid config
+    exitKilled  = 3 // some runs in flight were killed
+)
+
+func main() {
+    flag.Parse()
+    if *checkOnly {
+        over := periodic.JobConfig{StartTime: *startTime, Interval: *intervalSec, Cron: *cronSpec}
+        if _, err := periodic.LoadConfig(*configFile, over); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(exitConfig)
+        }
+        fmt.Printf("config %s ok\n", *configFile)
+        os.Exit(exitOk)
+    }
+
+    if *previewN > 0 { os.Exit(preview()) }
+
+    p, err := periodic.NewPeriodicRunner(*configFile, *startTime, *intervalSec, *cronSpec)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(exitConfig)
+    }
+    done := sigworker.Start()
+    if *runOnce != "" { os.Exit(once(p, done)) }
+    p.RunNow = *runNow
+    go p.Run()
+    if *httpAddr != "" {
+        p.Printf("serving the api on %s", *httpAddr)
+        go func() {
+            if err := http.ListenAndServe(*httpAddr, p.Handler()); err != nil { p.Printf("api: %v", err) }
+        }()
+    }
+
+    // reload the config on SIGHUP, the file is watched for changes as well
+    hup := make(chan os.Signal, 1)
+    signal.Notify(hup, syscall.SIGHUP)
+    go func() {
+        for range hup { p.Reload() }
+    }()
+
+    p.Printf("received %v", <-done)
+    if err := p.Shutdown(); err != nil {
+        os.Exit(exitKilled)
+    }
+    os.Exit(exitOk)
+}
+
+// once runs the job named by -once, killed on a signal, it returns the exit
+// status: the one of the command, 1 if it did not exit by itself.
+func once(p *periodic.PeriodicRunner, done <-chan os.Signal) int {
+    ctx, cancel := context.WithCancel(context.Background())
+    go func() {
+        p.Printf("received %v, kill the run", <-done)
+        cancel()
+    }()
+    name := *runOnce
+    if name == "_" { name = "" }
+    r, err := p.RunOnce(ctx, name)
+    switch {
+    case err != nil:
+        fmt.Fprintln(os.Stderr, err)
+        return exitConfig
+    case r == nil || r.Err == nil:
+        return exitOk
+    case r.ExitCode > 0:
+        return r.ExitCode
+    }
+    return exitFlagged
+}
+
+// preview prints the next fire times of the jobs, it returns the exit status.
+func preview() int {
+    over := periodic.JobConfig{StartTime: *startTime, Interval: *intervalSec, Cron: *cronSpec}
+    cfg, err := periodic.LoadConfig(*configFile, over)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitConfig
+    }
+    var loc *time.Location
+    if *previewTz != "" {
+        if loc, err = time.LoadLocation(*previewTz); err != nil {
+            fmt.Fprintln(os.Stderr, "-tz:", err)
+            return exitConfig
+        }
+    }
+    previews, err := periodic.PreviewConfig(cfg, time.Now(), *previewN, loc, time.Duration(*minGap) * time.Second)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return exitConfig
+    }
+
+    status := exitOk
+    for _, p := range previews {
+        name := p.Job
+        if name == "" { name = "(top level)" }
+        fmt.Printf("%s: %s, %s\n", name, p.Schedule, p.Timezone)
+        for i, t := range p.Fires {
+            note := ""
+            if p.Notes[i] != "" { note = " (" + p.Notes[i] + ")" }
+            fmt.Printf("    %s%s\n", t.Format("Mon 2006-01-02 15:04:05 MST"), note)
+        }
+        for _, w := range p.Warnings {
+            fmt.Printf("    WARNING: %s\n", w)
+            status = exitFlagged
+        }
+    }
+    return status
+}
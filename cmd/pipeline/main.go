@@ -0,0 +1,70 @@
+// Go Concurrency Pattern: Pipelines and Cancellation & Worker Pool
+//
+// Print the MD5 sums of all files under a directory with one of the digest
+// strategies, or compare two trees on different hosts:
+//    pipeline -serve localhost:8080 /path/to/a
+//    pipeline -remote http://localhost:8080 /path/to/b
+//
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "sort"
+
+    "github.com/xzturn/go-by-example/digest"
+)
+
+var workType *int = flag.Int("t", 0, "FileDigester Type: 0, 1, 2, 3 or 4")
+var serveAddr *string = flag.String("serve", ``, "serve digests of root over http on ${host:port}")
+var remoteUrl *string = flag.String("remote", ``, "compare root with the digests served at ${http://host:port}")
+
+func main() {
+    flag.Parse()
+
+    // Calculate the MD5 sum of all files under the specified directory,
+    // then print the results sorted by path name.
+    // the timing goes to stderr when the output is a diff or a server log
+    timing := io.Writer(os.Stdout)
+    if *serveAddr != "" || *remoteUrl != "" { timing = os.Stderr }
+    p := digest.New(*workType, digest.Options{Timing: timing})
+
+    root := "."
+    if flag.NArg() > 0 {
+        root = flag.Arg(0)
+    }
+
+    switch {
+    case *serveAddr != "":
+        log.Printf("serving digests of %s on %s", root, *serveAddr)
+        log.Fatal(http.ListenAndServe(*serveAddr, digest.NewHandler(p, root)))
+    case *remoteUrl != "":
+        diffs, err := digest.CompareRemote(p, root, *remoteUrl)
+        if err != nil {
+            fmt.Println(err)
+            os.Exit(2)
+        }
+        for _, diff := range diffs { fmt.Println(diff) }
+        if len(diffs) > 0 { os.Exit(1) }
+        fmt.Println("identical")
+        return
+    }
+
+    m, err := p.MD5All(root)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    var paths []string
+    for path := range m {
+        paths = append(paths, path)
+    }
+    sort.Strings(paths)
+    for _, path := range paths {
+        fmt.Printf("%x  %s\n", m[path], path)
+    }
+}
@@ -0,0 +1,17 @@
+// Go singal process
+//
+package main
+
+import (
+    "fmt"
+
+    "github.com/xzturn/go-by-example/sigworker"
+)
+
+func main() {
+    done := sigworker.Start()
+    fmt.Println("awaiting signal")
+
+    fmt.Printf("\n%v\n", <-done) // main go routine waits here
+    fmt.Println("exiting")
+}
@@ -0,0 +1,25 @@
+// Go Concurrency Pattern: Ticker
+//
+package main
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+func workProcess(idx *int) {
+    fmt.Printf("Working [%d] ... ...\n", *idx)
+    *idx++
+}
+
+func main() {
+    t, n := time.Duration(1) * time.Second, 10
+    times, quit := schedule.PeriodicWorker(t, workProcess)
+    for i := range times {   // main routine wating on times
+        if i >= n {          // loop n times, then send quit signal
+            quit <- struct{}{}
+        }
+    }
+}
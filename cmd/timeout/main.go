@@ -6,24 +6,26 @@ import (
     "fmt"
     "math/rand"
     "time"
+
+    "github.com/xzturn/go-by-example/schedule"
 )
 
-func demoWorker() <-chan int {
+func demoWorker(clock schedule.Clock) <-chan int {
     cost := make(chan int)
     go func() {
         // sleep rand 2 ~ 6 seconds as if it is working
         t := 2 + rand.Int() % 5
-        time.Sleep(time.Duration(t) * time.Second)
+        clock.Sleep(time.Duration(t) * time.Second)
         cost <- t
     }()
     return cost
 }
 
-func timeoutWorker(n int) <-chan struct{} {
+func timeoutWorker(clock schedule.Clock, n int) <-chan struct{} {
     timeout := make(chan struct{})
     go func() {
         // sleep n seconds for timeout
-        time.Sleep(time.Duration(n) * time.Second)
+        clock.Sleep(time.Duration(n) * time.Second)
         timeout <- struct{}{}
     }()
     return timeout
@@ -32,11 +34,11 @@ func timeoutWorker(n int) <-chan struct{} {
 func main() {
     rand.Seed(time.Now().Unix())
 
-    n := 5
+    n, clock := 5, schedule.Real
     select {
-    case cost := <-demoWorker():
+    case cost := <-demoWorker(clock):
         fmt.Printf("Work Complete, cost: %d seconds\n", cost)
-    case <-timeoutWorker(n):
+    case <-timeoutWorker(clock, n):
         fmt.Printf("Timeout after %d seconds\n", n)
     }
 }
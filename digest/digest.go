@@ -1,38 +1,109 @@
-// Go Concurrency Pattern: Pipelines and Cancellation & Worker Pool
+// Package digest implements several strategies of the Go Concurrency Pattern:
+// Pipelines and Cancellation & Worker Pool, all computing the MD5 sums of the
+// files of a tree.  It also serves and compares such digests over http.
 //
-package main
+package digest
 
 import (
     "crypto/md5"
     "errors"
-    "flag"
     "fmt"
+    "io"
     "io/ioutil"
     "os"
     "path/filepath"
-    "sort"
     "sync"
     "time"
-)
 
-var workType *int = flag.Int("t", 0, "FileDigester Type: 0, 1, 2, or 3")
+    "github.com/xzturn/go-by-example/metrics"
+)
 
-// A result is the product of reading and summing a file using MD5.
-type result struct {
-    path string
-    sum  [md5.Size]byte
-    err  error
+// A Result is the product of reading and summing a file using MD5.
+type Result struct {
+    Path string
+    Sum  [md5.Size]byte
+    Err  error
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// IFileDigester is implemented by every digest strategy of this package.
+// MD5All reads all the files in the file tree rooted at root and returns a map
+// from file path to the MD5 sum of the file's contents.
 type IFileDigester interface {
-    MD5All(string) (map[string][md5.Size]byte, error)
+    MD5All(root string) (map[string][md5.Size]byte, error)
+}
+
+// Options are shared by all the digest strategies, the zero value is usable.
+type Options struct {
+    // Digesters bounds the number of digesting go routines of FileDigester2,
+    // DefaultDigesters if <= 0.  The other strategies start one go routine
+    // per file or digest serially.
+    Digesters int
+
+    // Timing, if not nil, receives the execution time of each MD5All call.
+    Timing io.Writer
+}
+
+// DefaultDigesters is the number of digesting go routines of FileDigester2
+// unless specified by Options.Digesters.
+const DefaultDigesters = 20
+
+func (o Options) digesters() int {
+    if o.Digesters <= 0 { return DefaultDigesters }
+    return o.Digesters
+}
+
+// The metrics of all the digest strategies, labeled by strategy name.
+var (
+    filesHashed = metrics.Default.Counter("digest_files_hashed_total", "Files read and summed.", "strategy")
+    bytesHashed = metrics.Default.Counter("digest_bytes_hashed_total", "Bytes read and summed.", "strategy")
+    md5AllTime  = metrics.Default.Histogram("digest_md5all_duration_seconds", "Execution time of MD5All.", nil, "strategy")
+)
+
+// timing reports the execution time since ts, use it as
+//    defer o.timing("Name", time.Now())
+func (o Options) timing(name string, ts time.Time) {
+    d := time.Now().Sub(ts)
+    md5AllTime.Observe(d.Seconds(), name)
+    if o.Timing != nil {
+        fmt.Fprintf(o.Timing, "%s.MD5All() execute time: %v\n", name, d)
+    }
+}
+
+// sumFile reads and sums the file at path for the strategy named name.
+func sumFile(name, path string) Result {
+    data, err := ioutil.ReadFile(path)
+    if err == nil {
+        filesHashed.Inc(name)
+        bytesHashed.Add(float64(len(data)), name)
+    }
+    return Result{path, md5.Sum(data), err}
+}
+
+// New returns the digest strategy numbered t, as selected by the -t flag of
+// cmd/pipeline: 1 to 4 are FileDigester1 to FileDigester4, others FileDigester.
+func New(t int, opts Options) IFileDigester {
+    switch t {
+    case 1:
+        return &FileDigester1{opts}
+    case 2:
+        return &FileDigester2{opts}
+    case 3:
+        return &FileDigester3{opts}
+    case 4:
+        return &FileDigester4{opts}
+    default:
+        return &FileDigester{opts}
+    }
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// FileDigester collects all the files first, then digests them in a pool of
+// one go routine per file, fed by index.
 type FileDigester struct {
+    Options
 }
 
 // walk through all the files and sub-dirs, no concurrency
@@ -53,12 +124,11 @@ func (p FileDigester) walk(root string, files *[]string) error {
 }
 
 // define how each worker work, wait for idx signal or done signal
-func (p FileDigester) md5Worker(files []string, cidx <-chan int, done <-chan struct{}, res *[]result) {
+func (p FileDigester) md5Worker(files []string, cidx <-chan int, done <-chan struct{}, res *[]Result) {
     for {
         select {
         case idx:= <-cidx:
-            data, err := ioutil.ReadFile(files[idx])
-            (*res)[idx] = result{files[idx], md5.Sum(data), err}
+            (*res)[idx] = sumFile("FileDigester", files[idx])
         case <-done:
             return
         }
@@ -68,10 +138,7 @@ func (p FileDigester) md5Worker(files []string, cidx <-chan int, done <-chan str
 // worker pool: collect candicate files first,
 // then use N go routines to process each file, use cancel
 func (p FileDigester) MD5All(root string) (map[string][md5.Size]byte, error) {
-    ts := time.Now()
-    defer func() {
-        fmt.Printf("FileDigester.MD5All() execute time: %v\n", time.Now().Sub(ts))
-    }()
+    defer p.timing("FileDigester", time.Now())
 
     files := make([]string, 0)
     if err := p.walk(root, &files); err != nil { return nil, err }
@@ -79,7 +146,7 @@ func (p FileDigester) MD5All(root string) (map[string][md5.Size]byte, error) {
     n := len(files)
     cidx := make(chan int)
     done := make(chan struct{})
-    res := make([]result, n)
+    res := make([]Result, n)
 
     for i := 0; i < n; i++ { go p.md5Worker(files, cidx, done, &res) }
     for i := 0; i < n; i++ { cidx <- i }
@@ -87,27 +154,29 @@ func (p FileDigester) MD5All(root string) (map[string][md5.Size]byte, error) {
 
     m := make(map[string][md5.Size]byte)
     for _, r := range res {
-        if r.err != nil {
-            return nil, r.err
+        if r.Err != nil {
+            return nil, r.Err
         }
-        m[r.path] = r.sum
+        m[r.Path] = r.Sum
     }
     return m, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// FileDigester1 starts one go routine per file while walking the tree.
 type FileDigester1 struct {
+    Options
 }
 
 // sumFiles starts goroutines to walk the directory tree at root and digest each
 // regular file.  These goroutines send the results of the digests on the result
 // channel and send the result of the walk on the error channel.  If done is
 // closed, sumFiles abandons its work.
-func (p FileDigester1) sumFiles(done <-chan struct{}, root string) (<-chan result, <-chan error) {
+func (p FileDigester1) sumFiles(done <-chan struct{}, root string) (<-chan Result, <-chan error) {
     // For each regular file, start a goroutine that sums the file and sends
     // the result on c.  Send the result of the walk on errc.
-    c := make(chan result)
+    c := make(chan Result)
     errc := make(chan error, 1)
     go func() { // HL
         var wg sync.WaitGroup
@@ -120,9 +189,8 @@ func (p FileDigester1) sumFiles(done <-chan struct{}, root string) (<-chan resul
             }
             wg.Add(1)
             go func() { // HL
-                data, err := ioutil.ReadFile(path)
                 select {
-                case c <- result{path, md5.Sum(data), err}: // HL
+                case c <- sumFile("FileDigester1", path): // HL
                 case <-done: // HL
                 }
                 wg.Done()
@@ -152,10 +220,7 @@ func (p FileDigester1) sumFiles(done <-chan struct{}, root string) (<-chan resul
 // fails or any read operation fails, MD5All returns an error.  In that case,
 // MD5All does not wait for inflight read operations to complete.
 func (p FileDigester1) MD5All(root string) (map[string][md5.Size]byte, error) {
-    ts := time.Now()
-    defer func() {
-        fmt.Printf("FileDigester1.MD5All() execute time: %v\n", time.Now().Sub(ts))
-    }()
+    defer p.timing("FileDigester1", time.Now())
     // MD5All closes the done channel when it returns; it may do so before
     // receiving all the values from c and errc.
     done := make(chan struct{}) // HLdone
@@ -165,10 +230,10 @@ func (p FileDigester1) MD5All(root string) (map[string][md5.Size]byte, error) {
 
     m := make(map[string][md5.Size]byte)
     for r := range c { // HLrange
-        if r.err != nil {
-            return nil, r.err
+        if r.Err != nil {
+            return nil, r.Err
         }
-        m[r.path] = r.sum
+        m[r.Path] = r.Sum
     }
     if err := <-errc; err != nil {
         return nil, err
@@ -178,7 +243,10 @@ func (p FileDigester1) MD5All(root string) (map[string][md5.Size]byte, error) {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// FileDigester2 is a bounded pipeline: one go routine walks the tree and a
+// fixed number of digesters read and sum the files.
 type FileDigester2 struct {
+    Options
 }
 
 // walkFiles starts a goroutine to walk the directory tree at root and send the
@@ -211,11 +279,10 @@ func (p FileDigester2) walkFiles(done <-chan struct{}, root string) (<-chan stri
 
 // digester reads path names from paths and sends digests of the corresponding
 // files on c until either paths or done is closed.
-func (p FileDigester2) digester(done <-chan struct{}, paths <-chan string, c chan<- result) {
+func (p FileDigester2) digester(done <-chan struct{}, paths <-chan string, c chan<- Result) {
     for path := range paths { // HLpaths
-        data, err := ioutil.ReadFile(path)
         select {
-        case c <- result{path, md5.Sum(data), err}:
+        case c <- sumFile("FileDigester2", path):
         case <-done:
             return
         }
@@ -227,10 +294,7 @@ func (p FileDigester2) digester(done <-chan struct{}, paths <-chan string, c cha
 // fails or any read operation fails, MD5All returns an error.  In that case,
 // MD5All does not wait for inflight read operations to complete.
 func (p FileDigester2) MD5All(root string) (map[string][md5.Size]byte, error) {
-    ts := time.Now()
-    defer func() {
-        fmt.Printf("FileDigester2.MD5All() execute time: %v\n", time.Now().Sub(ts))
-    }()
+    defer p.timing("FileDigester2", time.Now())
     // MD5All closes the done channel when it returns; it may do so before
     // receiving all the values from c and errc.
     done := make(chan struct{})
@@ -239,9 +303,9 @@ func (p FileDigester2) MD5All(root string) (map[string][md5.Size]byte, error) {
     paths, errc := p.walkFiles(done, root)
 
     // Start a fixed number of goroutines to read and digest files.
-    c := make(chan result) // HLc
+    c := make(chan Result) // HLc
     var wg sync.WaitGroup
-    const numDigesters = 20
+    numDigesters := p.digesters()
     wg.Add(numDigesters)
     for i := 0; i < numDigesters; i++ {
         go func() {
@@ -257,10 +321,10 @@ func (p FileDigester2) MD5All(root string) (map[string][md5.Size]byte, error) {
 
     m := make(map[string][md5.Size]byte)
     for r := range c {
-        if r.err != nil {
-            return nil, r.err
+        if r.Err != nil {
+            return nil, r.Err
         }
-        m[r.path] = r.sum
+        m[r.Path] = r.Sum
     }
     // Check whether the Walk failed.
     if err := <-errc; err != nil { // HLerrc
@@ -271,7 +335,10 @@ func (p FileDigester2) MD5All(root string) (map[string][md5.Size]byte, error) {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// FileDigester3 walks each directory in its own go routine and digests the
+// files serially as they are found.
 type FileDigester3 struct {
+    Options
 }
 
 // for each go routine, it will walk through a directory
@@ -296,10 +363,7 @@ func (p FileDigester3) walk(root string, cpath chan<- string) <-chan error {
 
 // process file by file actually, just collect in concurrent pattern
 func (p FileDigester3) MD5All(root string) (map[string][md5.Size]byte, error) {
-    ts := time.Now()
-    defer func() {
-        fmt.Printf("FileDigester3.MD5All() execute time: %v\n", time.Now().Sub(ts))
-    }()
+    defer p.timing("FileDigester3", time.Now())
 
     cpath := make(chan string)
     cerr := p.walk(root, cpath)
@@ -307,9 +371,9 @@ func (p FileDigester3) MD5All(root string) (map[string][md5.Size]byte, error) {
     for {
         select {
         case path := <-cpath:
-            data, err := ioutil.ReadFile(path)
-            if err != nil { return nil, err }
-            m[path] = md5.Sum(data)
+            r := sumFile("FileDigester3", path)
+            if r.Err != nil { return nil, r.Err }
+            m[path] = r.Sum
         case err := <-cerr:
             return m, err
         }
@@ -318,7 +382,10 @@ func (p FileDigester3) MD5All(root string) (map[string][md5.Size]byte, error) {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// FileDigester4 collects all the files first, then digests them in a pool of
+// one go routine per file, fed by a buffered channel.
 type FileDigester4 struct {
+    Options
 }
 
 // walk through all the files and sub-dirs, collect all candidates
@@ -339,27 +406,23 @@ func (p FileDigester4) walk(root string, files *[]string) error {
 }
 
 // define how each worker work, wait for cfile signal (buffered)
-func (p FileDigester4) md5Worker(cfile <-chan string, cres chan<- result) {
+func (p FileDigester4) md5Worker(cfile <-chan string, cres chan<- Result) {
     for file := range cfile {
-        data, err := ioutil.ReadFile(file)
-        cres <- result{file, md5.Sum(data), err}
+        cres <- sumFile("FileDigester4", file)
     }
 }
 
 // worker pool: collect candicate files first,
 // then use N go routines to process each file
 func (p FileDigester4) MD5All(root string) (map[string][md5.Size]byte, error) {
-    ts := time.Now()
-    defer func() {
-        fmt.Printf("FileDigester4.MD5All() execute time: %v\n", time.Now().Sub(ts))
-    }()
+    defer p.timing("FileDigester4", time.Now())
 
     files := make([]string, 0)
     if err := p.walk(root, &files); err != nil { return nil, err }
 
     n := len(files)
     cfile := make(chan string, n)
-    cres := make(chan result, n)
+    cres := make(chan Result, n)
 
     for i := 0; i < n; i++ { go p.md5Worker(cfile, cres) }
     for i := 0; i < n; i++ { cfile <- files[i] }
@@ -368,53 +431,12 @@ func (p FileDigester4) MD5All(root string) (map[string][md5.Size]byte, error) {
     m := make(map[string][md5.Size]byte)
     for i := 0; i < n; i++ {
         r := <-cres
-        if r.err != nil {
-            return nil, r.err
+        if r.Err != nil {
+            return nil, r.Err
         }
-        m[r.path] = r.sum
+        m[r.Path] = r.Sum
     }
     close(cres)
 
     return m, nil
 }
-
-////////////////////////////////////////////////////////////////////////////////
-
-func main() {
-    flag.Parse()
-
-    // Calculate the MD5 sum of all files under the specified directory,
-    // then print the results sorted by path name.
-    var p IFileDigester
-    switch *workType {
-    case 1:
-        p = &FileDigester1{}
-    case 2:
-        p = &FileDigester2{}
-    case 3:
-        p = &FileDigester3{}
-    case 4:
-        p = &FileDigester4{}
-    default:
-        p = &FileDigester{}
-    }
-
-    root := "."
-    if flag.NArg() > 0 {
-        root = flag.Arg(0)
-    }
-
-    m, err := p.MD5All(root)
-    if err != nil {
-        fmt.Println(err)
-        return
-    }
-    var paths []string
-    for path := range m {
-        paths = append(paths, path)
-    }
-    sort.Strings(paths)
-    for _, path := range paths {
-        fmt.Printf("%x  %s\n", m[path], path)
-    }
-}
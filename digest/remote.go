@@ -0,0 +1,158 @@
+// Remote digest: serve a tree's digests over http and compare with a peer
+//
+package digest
+
+import (
+    "crypto/md5"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    pathpkg "path"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/xzturn/go-by-example/metrics"
+)
+
+// A TreeDigest holds the MD5 sums of a file tree, keyed by slash separated
+// paths relative to its root, so that two hosts can compare their trees even
+// if they are rooted at different places.  Dirs holds the Merkle hash of each
+// directory, the root directory is keyed by ".".
+type TreeDigest struct {
+    Files map[string]string `json:"files"`
+    Dirs  map[string]string `json:"dirs"`
+}
+
+// depth of a slash separated relative path, the root "." is the shallowest
+func depth(dir string) int {
+    if dir == "." { return -1 }
+    return strings.Count(dir, "/")
+}
+
+// merkle hashes each directory over the sorted names and hashes of its direct
+// children, so two directories hash equal iff their subtrees are identical.
+func merkle(files map[string][md5.Size]byte) map[string][md5.Size]byte {
+    children := map[string][]string{".": nil}
+    hashes := make(map[string][md5.Size]byte)
+    for file, sum := range files {
+        hashes[file] = sum
+        for child, dir := file, pathpkg.Dir(file); ; child, dir = dir, pathpkg.Dir(dir) {
+            _, seen := children[dir]
+            children[dir] = append(children[dir], child)
+            if seen || dir == "." { break }
+        }
+    }
+
+    // deeper directories go first, so every child is hashed before its parent
+    var dirs []string
+    for dir := range children { dirs = append(dirs, dir) }
+    sort.Slice(dirs, func(i, j int) bool { return depth(dirs[i]) > depth(dirs[j]) })
+
+    m := make(map[string][md5.Size]byte)
+    for _, dir := range dirs {
+        names := children[dir]
+        sort.Strings(names)
+        h := md5.New()
+        for _, name := range names {
+            kind := "f"
+            if _, ok := children[name]; ok { kind = "d" }
+            fmt.Fprintf(h, "%s %s %x\n", kind, pathpkg.Base(name), hashes[name])
+        }
+        var sum [md5.Size]byte
+        copy(sum[:], h.Sum(nil))
+        m[dir], hashes[dir] = sum, sum
+    }
+    return m
+}
+
+// NewTreeDigest runs p.MD5All on root and relativizes the result to root.
+func NewTreeDigest(p IFileDigester, root string) (*TreeDigest, error) {
+    m, err := p.MD5All(root)
+    if err != nil { return nil, err }
+
+    files := make(map[string][md5.Size]byte)
+    for path, sum := range m {
+        rel, err := filepath.Rel(root, path)
+        if err != nil { return nil, err }
+        files[filepath.ToSlash(rel)] = sum
+    }
+
+    t := &TreeDigest{make(map[string]string), make(map[string]string)}
+    for path, sum := range files { t.Files[path] = hex.EncodeToString(sum[:]) }
+    for dir, sum := range merkle(files) { t.Dirs[dir] = hex.EncodeToString(sum[:]) }
+    return t, nil
+}
+
+// NewHandler returns a handler serving the TreeDigest of root as json:
+//    GET /merkle  returns the directory hashes only
+//    GET /digest  returns both the file and the directory hashes
+//    GET /metrics returns the metrics in the Prometheus text format
+// The tree is digested again on each request, so the peer always sees the
+// current state of the tree.
+func NewHandler(p IFileDigester, root string) http.Handler {
+    handler := func(filesToo bool) http.HandlerFunc {
+        return func(w http.ResponseWriter, r *http.Request) {
+            t, err := NewTreeDigest(p, root)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusInternalServerError)
+                return
+            }
+            if !filesToo { t.Files = nil }
+            w.Header().Set("Content-Type", "application/json")
+            json.NewEncoder(w).Encode(t)
+        }
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc("/merkle", handler(false))
+    mux.HandleFunc("/digest", handler(true))
+    mux.Handle("/metrics", metrics.Default.Handler())
+    return mux
+}
+
+func fetchDigest(url string) (*TreeDigest, error) {
+    resp, err := http.Get(url)
+    if err != nil { return nil, err }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        msg, _ := ioutil.ReadAll(resp.Body)
+        return nil, fmt.Errorf("%s: %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+    }
+    var t TreeDigest
+    if err := json.NewDecoder(resp.Body).Decode(&t); err != nil { return nil, err }
+    return &t, nil
+}
+
+// CompareRemote compares the tree at root with the one served at remote and
+// returns the differences as sorted lines of "<op> <path>", where op is one of
+//    -  the file only exists locally
+//    +  the file only exists remotely
+//    M  the file exists on both sides but differs
+// The root Merkle hashes are compared first, so identical trees cost a
+// single small request.
+func CompareRemote(p IFileDigester, root, remote string) ([]string, error) {
+    remote = strings.TrimSuffix(remote, "/")
+    local, err := NewTreeDigest(p, root)
+    if err != nil { return nil, err }
+
+    peer, err := fetchDigest(remote + "/merkle")
+    if err != nil { return nil, err }
+    if peer.Dirs["."] == local.Dirs["."] { return nil, nil }
+
+    if peer, err = fetchDigest(remote + "/digest"); err != nil { return nil, err }
+    var diffs []string
+    for path, sum := range local.Files {
+        if rsum, ok := peer.Files[path]; !ok {
+            diffs = append(diffs, "- "+path)
+        } else if rsum != sum {
+            diffs = append(diffs, "M "+path)
+        }
+    }
+    for path := range peer.Files {
+        if _, ok := local.Files[path]; !ok { diffs = append(diffs, "+ "+path) }
+    }
+    sort.Slice(diffs, func(i, j int) bool { return diffs[i][2:] < diffs[j][2:] })
+    return diffs, nil
+}
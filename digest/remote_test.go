@@ -0,0 +1,55 @@
+package digest
+
+import (
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// tree writes the files, keyed by slash separated paths, under a temporary
+// directory and returns it.
+func tree(t *testing.T, files map[string]string) string {
+    t.Helper()
+    root := t.TempDir()
+    for path, content := range files {
+        path = filepath.Join(root, filepath.FromSlash(path))
+        if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil { t.Fatal(err) }
+        if err := os.WriteFile(path, []byte(content), 0666); err != nil { t.Fatal(err) }
+    }
+    return root
+}
+
+func TestCompareRemote(t *testing.T) {
+    base := map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/deep/c.txt": "c"}
+    tests := []struct {
+        name   string
+        remote map[string]string
+        want   []string
+    }{
+        {"identical", base, nil},
+        {"local only", map[string]string{"a.txt": "a", "sub/b.txt": "b"}, []string{"- sub/deep/c.txt"}},
+        {"remote only", map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/deep/c.txt": "c", "sub/d.txt": "d"}, []string{"+ sub/d.txt"}},
+        {"modified", map[string]string{"a.txt": "A", "sub/b.txt": "b", "sub/deep/c.txt": "c"}, []string{"M a.txt"}},
+        {"all", map[string]string{"a.txt": "A", "sub/b.txt": "b", "z.txt": "z"}, []string{"M a.txt", "- sub/deep/c.txt", "+ z.txt"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            // both sides are rooted at different places, and digested by
+            // different strategies
+            server := httptest.NewServer(NewHandler(New(2, Options{}), tree(t, tt.remote)))
+            defer server.Close()
+
+            diffs, err := CompareRemote(New(0, Options{}), tree(t, base), server.URL + "/")
+            if err != nil { t.Fatal(err) }
+            if !reflect.DeepEqual(diffs, tt.want) { t.Fatalf("got %q, want %q", diffs, tt.want) }
+        })
+    }
+}
+
+func TestCompareRemoteError(t *testing.T) {
+    server := httptest.NewServer(NewHandler(New(0, Options{}), filepath.Join(t.TempDir(), "missing")))
+    defer server.Close()
+    if _, err := CompareRemote(New(0, Options{}), tree(t, nil), server.URL); err == nil { t.Fatal("expect an error") }
+}
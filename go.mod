@@ -0,0 +1,3 @@
+module github.com/xzturn/go-by-example
+
+go 1.22
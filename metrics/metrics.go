@@ -0,0 +1,228 @@
+// Package metrics implements counters, gauges and histograms exposed in the
+// Prometheus text format, hand-rolled on the standard library.
+//
+package metrics
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// The kinds of metric families.
+const (
+    counter   = "counter"
+    gauge     = "gauge"
+    histogram = "histogram"
+)
+
+// DefaultBuckets are the upper bounds of a histogram in seconds unless given.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// A Family is a metric and its series, one per combination of label values.
+// Its methods panic if called with the wrong number of label values, or if
+// they don't fit its kind.
+type Family struct {
+    name, help, kind string
+    labels  []string
+    buckets []float64
+
+    mu     sync.Mutex
+    series map[string]*series
+}
+
+type series struct {
+    values []string
+    value  float64  // the counter or gauge, the sum of a histogram
+    counts []uint64 // per bucket of a histogram, not cumulated
+    count  uint64
+}
+
+func (f *Family) get(kind string, values []string) *series {
+    if f.kind != kind { panic(fmt.Sprintf("metrics: %s is a %s, not a %s", f.name, f.kind, kind)) }
+    if len(values) != len(f.labels) { panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values))) }
+    key := strings.Join(values, "\xff")
+    s := f.series[key]
+    if s == nil {
+        s = &series{values: append([]string(nil), values...)}
+        if kind == histogram { s.counts = make([]uint64, len(f.buckets)) }
+        f.series[key] = s
+    }
+    return s
+}
+
+// Add v >= 0 to a counter.
+func (f *Family) Add(v float64, values ...string) {
+    if v < 0 { panic("metrics: " + f.name + ": a counter can't decrease") }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.get(counter, values).value += v
+}
+
+// Inc adds 1 to a counter.
+func (f *Family) Inc(values ...string) { f.Add(1, values...) }
+
+// Set a gauge to v.
+func (f *Family) Set(v float64, values ...string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.get(gauge, values).value = v
+}
+
+// Observe v in a histogram.
+func (f *Family) Observe(v float64, values ...string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    s := f.get(histogram, values)
+    s.value += v
+    s.count++
+    if i := sort.SearchFloat64s(f.buckets, v); i < len(f.buckets) { s.counts[i]++ }
+}
+
+// Delete the series of the given label values, e.g. of a removed job.
+func (f *Family) Delete(values ...string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    delete(f.series, strings.Join(values, "\xff"))
+}
+
+// write the family in the text format, series sorted by label values.
+func (f *Family) write(w *bufio.Writer) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if len(f.series) == 0 { return }
+    fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escape(f.help, false), f.name, f.kind)
+
+    keys := make([]string, 0, len(f.series))
+    for key := range f.series { keys = append(keys, key) }
+    sort.Strings(keys)
+    for _, key := range keys {
+        s := f.series[key]
+        if f.kind != histogram {
+            fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelSet(s.values, "", 0), format(s.value))
+            continue
+        }
+        var cum uint64
+        for i, le := range f.buckets {
+            cum += s.counts[i]
+            fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.values, "le", le), cum)
+        }
+        fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.values, "le", math.Inf(1)), s.count)
+        fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelSet(s.values, "", 0), format(s.value))
+        fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelSet(s.values, "", 0), s.count)
+    }
+}
+
+// labelSet returns {name="value",...}, with le if not empty, or "" if no labels.
+func (f *Family) labelSet(values []string, le string, bound float64) string {
+    var pairs []string
+    for i, l := range f.labels { pairs = append(pairs, l + `="` + escape(values[i], true) + `"`) }
+    if le != "" { pairs = append(pairs, le + `="` + format(bound) + `"`) }
+    if len(pairs) == 0 { return "" }
+    return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func format(v float64) string {
+    switch {
+    case math.IsInf(v, 1):
+        return "+Inf"
+    case math.IsInf(v, -1):
+        return "-Inf"
+    }
+    return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escape(s string, quote bool) string {
+    s = strings.ReplaceAll(s, `\`, `\\`)
+    s = strings.ReplaceAll(s, "\n", `\n`)
+    if quote { s = strings.ReplaceAll(s, `"`, `\"`) }
+    return s
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A Registry holds metric families, safe for concurrent use.
+type Registry struct {
+    mu       sync.Mutex
+    families map[string]*Family
+}
+
+// Default is the registry of the packages of this module.
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+    return &Registry{families: make(map[string]*Family)}
+}
+
+// register returns the family named name, registered once; it panics if the
+// name is registered with another kind or labels.
+func (r *Registry) register(f *Family) *Family {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if g, ok := r.families[f.name]; ok {
+        if g.kind != f.kind || strings.Join(g.labels, ",") != strings.Join(f.labels, ",") {
+            panic("metrics: " + f.name + " registered twice differently")
+        }
+        return g
+    }
+    f.series = make(map[string]*series)
+    r.families[f.name] = f
+    return f
+}
+
+// Counter registers a counter named name, with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Family {
+    return r.register(&Family{name: name, help: help, kind: counter, labels: labels})
+}
+
+// Gauge registers a gauge named name, with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Family {
+    return r.register(&Family{name: name, help: help, kind: gauge, labels: labels})
+}
+
+// Histogram registers a histogram named name, with the given sorted bucket
+// upper bounds, DefaultBuckets if nil, and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Family {
+    if buckets == nil { buckets = DefaultBuckets }
+    return r.register(&Family{name: name, help: help, kind: histogram, labels: labels, buckets: buckets})
+}
+
+// WriteTo writes all the families in the Prometheus text format, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+    r.mu.Lock()
+    families := make([]*Family, 0, len(r.families))
+    for _, f := range r.families { families = append(families, f) }
+    r.mu.Unlock()
+    sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+    cw := &countingWriter{w: w}
+    bw := bufio.NewWriter(cw)
+    for _, f := range families { f.write(bw) }
+    err := bw.Flush()
+    return cw.n, err
+}
+
+type countingWriter struct {
+    w io.Writer
+    n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.n += int64(n)
+    return n, err
+}
+
+// Handler serves the registry at e.g. /metrics.
+func (r *Registry) Handler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+        r.WriteTo(w)
+    })
+}
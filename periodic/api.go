@@ -0,0 +1,170 @@
+// HTTP status and control API of a PeriodicRunner
+//
+package periodic
+
+import (
+    "encoding/json"
+    "net/http"
+    "time"
+
+    "github.com/xzturn/go-by-example/metrics"
+)
+
+// historySize is the number of recent runs kept per job.
+const historySize = 20
+
+// RunInfo describes a completed run of a job.
+type RunInfo struct {
+    Run      int       `json:"run"` // 0 if skipped
+    Start    time.Time `json:"start"`
+    End      time.Time `json:"end"`
+    Duration float64   `json:"duration_in_seconds"`
+    ExitCode int       `json:"exit_code"`
+    Attempts int       `json:"attempts,omitempty"`
+    Status   string    `json:"status"` // "ok", the failure, or why it's skipped
+    Stdout   string    `json:"stdout,omitempty"`
+    Stderr   string    `json:"stderr,omitempty"`
+}
+
+// JobStatus describes a job and its recent runs, without their output.
+type JobStatus struct {
+    Name     string     `json:"name"`
+    Schedule string     `json:"schedule"`
+    Timezone string     `json:"timezone"`
+    Paused   bool       `json:"paused"`
+    Running  int        `json:"running"`
+    NextRun  *time.Time `json:"next_run,omitempty"`
+    LastRun  *time.Time `json:"last_run,omitempty"` // the scheduled time of the latest run
+    Recent   []RunInfo  `json:"recent"`            // the latest first
+}
+
+// remember the idx-th run started at start, its result r is nil if there is
+// no command to execute.
+func (j *Job) remember(idx int, start time.Time, r *RunResult) {
+    info := RunInfo{Run: idx, Start: start, End: j.runner.Clock.Now(), Status: r.status()}
+    if r != nil {
+        info.Start, info.End = r.Start, r.Start.Add(r.Duration)
+        info.ExitCode, info.Attempts, info.Stdout, info.Stderr = r.ExitCode, r.Attempts, r.Stdout, r.Stderr
+    }
+    info.Duration = info.End.Sub(info.Start).Seconds()
+    j.keep(info)
+}
+
+// keep info in the recent runs of the job.
+func (j *Job) keep(info RunInfo) {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    j.history = append(j.history, info)
+    if len(j.history) > historySize { j.history = j.history[len(j.history) - historySize:] }
+}
+
+// Runs returns the recent runs of the job with their output, the latest first.
+func (j *Job) Runs() []RunInfo {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    runs := make([]RunInfo, len(j.history))
+    for i, info := range j.history { runs[len(runs) - 1 - i] = info }
+    return runs
+}
+
+// Status returns the status of the job.
+func (j *Job) Status() JobStatus {
+    st := JobStatus{Name: j.Name, Schedule: j.describe(j.sched), Timezone: j.loc.String(), Recent: j.Runs()}
+    for i := range st.Recent { st.Recent[i].Stdout, st.Recent[i].Stderr = "", "" }
+    if last := j.runner.state.Job(j.Name).LastFire; !last.IsZero() { st.LastRun = &last }
+
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    st.Paused, st.Running = j.paused, j.running
+    if next := j.next; !next.IsZero() && !j.stopping() { st.NextRun = &next }
+    return st
+}
+
+// Pause the scheduled runs of the job, until Resume; the runs in flight go on
+// and Trigger still runs it.
+func (j *Job) Pause() {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    if !j.paused { j.Printf("paused") }
+    j.paused = true
+}
+
+// Resume the scheduled runs of a paused job.
+func (j *Job) Resume() {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    if j.paused { j.Printf("resumed") }
+    j.paused = false
+}
+
+// Trigger a run of the job now, subject to its overlap policy.
+func (j *Job) Trigger() {
+    j.Printf("triggered")
+    j.fire(j.runner.Clock.Now())
+}
+
+// Job returns the running job named name, nil if there is none.  The single
+// unnamed top level job is named "".
+func (p *PeriodicRunner) Job(name string) *Job {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    for _, job := range p.jobs {
+        if job.Name == name { return job }
+    }
+    return nil
+}
+
+// Jobs returns the status of all the running jobs.
+func (p *PeriodicRunner) Jobs() []JobStatus {
+    p.mu.Lock()
+    jobs := append([]*Job(nil), p.jobs...)
+    p.mu.Unlock()
+    st := make([]JobStatus, len(jobs))
+    for i, job := range jobs { st[i] = job.Status() }
+    return st
+}
+
+// Handler returns a handler of the status and control API, in json:
+//    GET  /jobs               the status of all the jobs
+//    GET  /jobs/{name}        the status of a job
+//    GET  /jobs/{name}/runs   the recent runs of a job, with their output
+//    POST /jobs/{name}/run    trigger a run now
+//    POST /jobs/{name}/pause  pause the scheduled runs
+//    POST /jobs/{name}/resume resume the scheduled runs
+//    GET  /metrics            the metrics in the Prometheus text format
+// The single unnamed top level job is named "_" here.
+func (p *PeriodicRunner) Handler() http.Handler {
+    reply := func(w http.ResponseWriter, code int, v any) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(code)
+        json.NewEncoder(w).Encode(v)
+    }
+    withJob := func(f func(w http.ResponseWriter, job *Job)) http.HandlerFunc {
+        return func(w http.ResponseWriter, r *http.Request) {
+            name := r.PathValue("name")
+            if name == "_" { name = "" }
+            job := p.Job(name)
+            if job == nil {
+                reply(w, http.StatusNotFound, map[string]string{"error": "no job " + r.PathValue("name")})
+                return
+            }
+            f(w, job)
+        }
+    }
+    control := func(action func(job *Job)) http.HandlerFunc {
+        return withJob(func(w http.ResponseWriter, job *Job) {
+            action(job)
+            reply(w, http.StatusAccepted, job.Status())
+        })
+    }
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) { reply(w, http.StatusOK, p.Jobs()) })
+    mux.HandleFunc("GET /jobs/{name}", withJob(func(w http.ResponseWriter, job *Job) { reply(w, http.StatusOK, job.Status()) }))
+    mux.HandleFunc("GET /jobs/{name}/runs", withJob(func(w http.ResponseWriter, job *Job) { reply(w, http.StatusOK, job.Runs()) }))
+    mux.HandleFunc("POST /jobs/{name}/run", control((*Job).Trigger))
+    mux.HandleFunc("POST /jobs/{name}/pause", control((*Job).Pause))
+    mux.HandleFunc("POST /jobs/{name}/resume", control((*Job).Resume))
+    mux.Handle("GET /metrics", metrics.Default.Handler())
+    return mux
+}
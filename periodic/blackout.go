@@ -0,0 +1,207 @@
+// Blackout windows, when the scheduled runs are skipped or deferred
+//
+package periodic
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// BlackoutConfig is the json config of a blackout window: from the time of
+// day From until To, on the given weekdays and dates only.  A To not after
+// From spans midnight; without From and To, the whole day.
+type BlackoutConfig struct {
+    Weekdays  []string `json:"weekdays"`   // e.g. ["sat", "sun"], any day if empty
+    From      string   `json:"from"`       // hh:mm:ss
+    To        string   `json:"to"`         // hh:mm:ss, excluded
+    DatesFile string   `json:"dates_file"` // of 2006-01-02 lines, and # comments, any day if empty
+    Timezone  string   `json:"timezone"`   // IANA name, local if empty
+}
+
+// A BlackoutPolicy tells what a job does with the scheduled runs falling in a
+// blackout window.
+type BlackoutPolicy string
+
+const (
+    BlackoutSkip   BlackoutPolicy = "skip"   // skip the run, the default
+    BlackoutDefer  BlackoutPolicy = "defer"  // run once the window closes, once for all the deferred runs
+    BlackoutIgnore BlackoutPolicy = "ignore" // run anyway
+)
+
+// ParseBlackoutPolicy returns the BlackoutPolicy named s, BlackoutSkip if s is empty.
+func ParseBlackoutPolicy(s string) (BlackoutPolicy, error) {
+    switch b := BlackoutPolicy(s); b {
+    case "":
+        return BlackoutSkip, nil
+    case BlackoutSkip, BlackoutDefer, BlackoutIgnore:
+        return b, nil
+    }
+    return BlackoutSkip, fmt.Errorf("blackout %q: expect skip, defer or ignore", s)
+}
+
+var weekdays = map[string]time.Weekday{"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday,
+    "wed": time.Wednesday, "thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday}
+
+// window builds the schedule.Window of the config, reading its dates file.
+func (b *BlackoutConfig) window() (*schedule.Window, error) {
+    w := &schedule.Window{Location: time.Local}
+    for _, name := range b.Weekdays {
+        wd, ok := weekdays[strings.ToLower(name)]
+        if !ok { return nil, fmt.Errorf("weekday %q: expect sun, mon, tue, wed, thu, fri or sat", name) }
+        w.Weekdays = append(w.Weekdays, wd)
+    }
+    var c JobConfig
+    if b.From != "" {
+        h, m, s, err := c.ParseHourMinSec(b.From)
+        if err != nil { return nil, fmt.Errorf("from %q: %v", b.From, err) }
+        w.From = h * 3600 + m * 60 + s
+    }
+    if b.To != "" {
+        h, m, s, err := c.ParseHourMinSec(b.To)
+        if err != nil { return nil, fmt.Errorf("to %q: %v", b.To, err) }
+        w.To = h * 3600 + m * 60 + s
+    }
+    if b.DatesFile != "" {
+        dates, err := readDates(b.DatesFile)
+        if err != nil { return nil, err }
+        w.Dates = dates
+    }
+    if b.Timezone != "" {
+        loc, err := time.LoadLocation(b.Timezone)
+        if err != nil { return nil, err }
+        w.Location = loc
+    }
+    return w, nil
+}
+
+// validate reports every problem of the config, its fields prefixed by path.
+func (b *BlackoutConfig) validate(e *ConfigError, path string) {
+    for i, name := range b.Weekdays {
+        if _, ok := weekdays[strings.ToLower(name)]; !ok { e.add(fmt.Sprintf("%sweekdays[%d]", path, i), "%q: expect sun, mon, tue, wed, thu, fri or sat", name) }
+    }
+    var c JobConfig
+    for _, f := range []struct{ name, value string }{{"from", b.From}, {"to", b.To}} {
+        if f.value == "" { continue }
+        if _, _, _, err := c.ParseHourMinSec(f.value); err != nil { e.add(path + f.name, "%q: %v", f.value, err) }
+    }
+    if b.DatesFile != "" {
+        if _, err := readDates(b.DatesFile); err != nil { e.add(path + "dates_file", "%v", err) }
+    }
+    if b.Timezone != "" {
+        if _, err := time.LoadLocation(b.Timezone); err != nil { e.add(path + "timezone", "%v", err) }
+    }
+}
+
+// readDates reads a file of dates as 2006-01-02, one per line, blank lines
+// and # comments are ignored.
+func readDates(path string) (map[string]bool, error) {
+    fp, err := os.Open(path)
+    if err != nil { return nil, err }
+    defer fp.Close()
+
+    dates := make(map[string]bool)
+    scanner := bufio.NewScanner(fp)
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        if i := strings.Index(line, "#"); i >= 0 { line = strings.TrimSpace(line[:i]) }
+        if line == "" { continue }
+        d, err := time.Parse("2006-01-02", line)
+        if err != nil { return nil, fmt.Errorf("%s:%d: expect 2006-01-02, got %q", path, n, line) }
+        dates[d.Format("2006-01-02")] = true
+    }
+    return dates, scanner.Err()
+}
+
+// blackoutWindows builds the blackout windows of cfg.
+func blackoutWindows(cfg *PeriodicConfig) ([]*schedule.Window, error) {
+    var windows []*schedule.Window
+    for i := range cfg.Blackouts {
+        w, err := cfg.Blackouts[i].window()
+        if err != nil { return nil, fmt.Errorf("blackouts[%d]: %v", i, err) }
+        windows = append(windows, w)
+    }
+    return windows, nil
+}
+
+// maxBlackouts bounds the windows chained by blackoutEnd, in case they cover
+// all the time.
+const maxBlackouts = 1000
+
+// blackoutEnd returns the first time from t out of all the windows, and
+// whether t is within one.  It's zero if the windows never end.
+func blackoutEnd(windows []*schedule.Window, t time.Time) (time.Time, bool) {
+    in := false
+    for n := 0; n < maxBlackouts; n++ {
+        found := false
+        for _, w := range windows {
+            if end := w.End(t); !end.IsZero() {
+                t, found, in = end, true, true
+            }
+        }
+        if !found { return t, in }
+    }
+    return time.Time{}, true
+}
+
+// blackout returns when the blackout at t closes, and whether t is in one.
+func (p *PeriodicRunner) blackout(t time.Time) (time.Time, bool) {
+    p.mu.Lock()
+    windows := p.blackouts
+    p.mu.Unlock()
+    return blackoutEnd(windows, t)
+}
+
+// dispatch calls run, of the job firing at the time at, after the splay d,
+// unless the job stops first, or the run is in a blackout window: then, per
+// the blackout policy of the job, it's skipped, deferred until the window
+// closes unless a run is deferred already, or run anyway.
+func (j *Job) dispatch(at time.Time, d time.Duration, run func()) {
+    deferral := false
+    if end, in := j.runner.blackout(at.Add(d)); in && j.blackoutPolicy != BlackoutIgnore {
+        j.mu.Lock()
+        deferred := j.deferred
+        if !deferred && !end.IsZero() && j.blackoutPolicy == BlackoutDefer { j.deferred = true }
+        j.mu.Unlock()
+        switch {
+        case j.blackoutPolicy == BlackoutSkip:
+            j.Printf("skip run: blackout until %v", end)
+            return
+        case end.IsZero():
+            j.Printf("skip run: blackout never ends")
+            return
+        case deferred:
+            j.Printf("skip run: one is already deferred until %v", end)
+            return
+        }
+        j.Printf("defer run until the blackout ends at %v", end)
+        d, deferral = end.Sub(j.runner.Clock.Now()), true
+    } else if d > 0 {
+        j.Printf("splay run by %v", d)
+    }
+
+    // only the deferred run ends the deferral, not a splayed one
+    do := func() {
+        if deferral {
+            j.mu.Lock()
+            j.deferred = false
+            j.mu.Unlock()
+        }
+        run()
+    }
+    if d <= 0 {
+        do()
+        return
+    }
+    go func() {
+        select {
+        case <-j.quit:
+        case <-j.runner.Clock.After(d):
+            do()
+        }
+    }()
+}
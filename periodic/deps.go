@@ -0,0 +1,147 @@
+// Dependencies between the jobs of a PeriodicRunner, forming a DAG
+//
+package periodic
+
+import (
+    "fmt"
+    "strings"
+)
+
+// checkDeps reports the unknown, repeated and cyclic dependencies of jobs,
+// whose names are given by names.
+func checkDeps(e *ConfigError, jobs []JobConfig, names map[string]int) {
+    for i, c := range jobs {
+        seen := make(map[string]bool)
+        for _, up := range c.After {
+            path := fmt.Sprintf("jobs[%d].after", i)
+            j, ok := names[up]
+            switch {
+            case !ok:
+                e.add(path, "no job %q", up)
+            case j == i:
+                e.add(path, "%q depends on itself", up)
+            case seen[up]:
+                e.add(path, "%q listed twice", up)
+            }
+            seen[up] = true
+        }
+    }
+
+    // depth first search, a job met again while on the path closes a cycle
+    const (
+        unvisited = iota
+        onPath
+        visited
+    )
+    mark := make([]int, len(jobs))
+    var path []string
+    var visit func(i int, name string)
+    visit = func(i int, name string) {
+        mark[i] = onPath
+        path = append(path, name)
+        for _, up := range jobs[i].After {
+            j, ok := names[up]
+            if !ok || j == i { continue }
+            switch mark[j] {
+            case unvisited:
+                visit(j, up)
+            case onPath:
+                for k, n := range path {
+                    if n == up { e.add(fmt.Sprintf("jobs[%d].after", i), "cycle %s -> %s", strings.Join(path[k:], " -> "), up) }
+                }
+            }
+        }
+        path = path[:len(path) - 1]
+        mark[i] = visited
+    }
+    for name, i := range names {
+        if mark[i] == unvisited { visit(i, name) }
+    }
+}
+
+// lockOwners maps the name of each job of a validated config to the name of
+// the job whose lock it runs under: the first one without dependencies of its
+// component of the DAG, so that the whole component runs on one instance.
+func lockOwners(jobs []JobConfig) map[string]string {
+    // union find of the components
+    parent := make(map[string]string)
+    var find func(name string) string
+    find = func(name string) string {
+        up, ok := parent[name]
+        if !ok || up == name { return name }
+        parent[name] = find(up)
+        return parent[name]
+    }
+    for _, c := range jobs {
+        for _, up := range c.After { parent[find(c.Name)] = find(up) }
+    }
+
+    roots, owners := make(map[string]string), make(map[string]string)
+    for _, c := range jobs {
+        if _, ok := roots[find(c.Name)]; !ok && len(c.After) == 0 { roots[find(c.Name)] = c.Name }
+    }
+    for _, c := range jobs { owners[c.Name] = roots[find(c.Name)] }
+    return owners
+}
+
+// downstream returns the jobs running after the job named name.
+func (p *PeriodicRunner) downstream(name string) []*Job {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    var jobs []*Job
+    for _, job := range p.jobs {
+        for _, up := range job.After {
+            if up == name { jobs = append(jobs, job) }
+        }
+    }
+    return jobs
+}
+
+// completed tells the downstream jobs that the job completed a run, ok if it
+// succeeded, or that it skipped one.
+func (j *Job) completed(ok bool) {
+    for _, down := range j.runner.downstream(j.Name) { down.upstreamDone(j.Name, ok) }
+}
+
+// upstreamDone records that the upstream job named name completed a run, ok
+// if it succeeded.  Once all the upstream jobs completed a run since the
+// latest one of the job, the job fires if they all succeeded and it isn't
+// paused, or else skips its run, and so the runs of the jobs after it in turn.
+func (j *Job) upstreamDone(name string, ok bool) {
+    j.mu.Lock()
+    if j.upstream == nil { j.upstream = make(map[string]bool) }
+    j.upstream[name] = ok
+    if len(j.upstream) < len(j.After) {
+        j.mu.Unlock()
+        return
+    }
+    var failed []string
+    for _, up := range j.After {
+        if !j.upstream[up] { failed = append(failed, fmt.Sprintf("%q", up)) }
+    }
+    j.upstream = nil
+    paused := j.paused
+    j.mu.Unlock()
+
+    if len(failed) > 0 {
+        j.skip("upstream", "upstream " + strings.Join(failed, ", ") + " failed or skipped")
+        return
+    }
+    if paused {
+        j.skip("paused", "paused")
+        return
+    }
+    j.Printf("upstream %s succeeded, run", strings.Join(j.After, ", "))
+    j.fire(j.runner.Clock.Now())
+}
+
+// skip a run of the job, for the given reason, counted in the metrics by
+// cause: upstream or paused.
+func (j *Job) skip(cause, reason string) {
+    if j.stopping() { return }
+    j.Printf("skip run: %s", reason)
+    runsSkipped.Inc(j.Name, cause)
+    now := j.runner.Clock.Now()
+    j.keep(RunInfo{Start: now, End: now, ExitCode: -1, Status: "skipped: " + reason})
+    j.completed(false)
+}
@@ -0,0 +1,88 @@
+// Execute the configured command of a PeriodicRunner
+//
+package periodic
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// A RunResult records one execution of the configured command.
+type RunResult struct {
+    Start    time.Time
+    Duration time.Duration
+    ExitCode int    // -1 if the command did not exit by itself
+    Stdout   string
+    Stderr   string
+    Err      error  // nil iff the command exited with 0 in time
+    Attempts int    // the number of executions, see RetryConfig
+}
+
+// status is "ok" or the failure of the run, nil r stands for no command.
+func (r *RunResult) status() string {
+    switch {
+    case r == nil || r.Err == nil:
+        return "ok"
+    case r.Attempts > 1:
+        return fmt.Sprintf("failed after %d attempts: %v", r.Attempts, r.Err)
+    }
+    return r.Err.Error()
+}
+
+// DefaultKillGrace is how long a timed out or killed command has to exit on
+// SIGTERM before SIGKILL, unless configured.
+const DefaultKillGrace = 5 * time.Second
+
+// LimitsConfig is the json config of the resource limits of a command, each
+// one unlimited if <= 0.
+type LimitsConfig struct {
+    CPU       int `json:"cpu_in_seconds"` // of CPU time, SIGXCPU then SIGKILL once over
+    Memory    int `json:"memory_in_mb"`   // of address space
+    OpenFiles int `json:"open_files"`
+}
+
+// execute runs the command once.  Once the configured timeout expires or ctx
+// is canceled, its process group gets SIGTERM, then SIGKILL after the grace
+// period, as told by clock.
+func (c *JobConfig) execute(ctx context.Context, clock schedule.Clock) *RunResult {
+    cancel := context.CancelFunc(func() {})
+    if c.Timeout > 0 {
+        ctx, cancel = context.WithTimeout(ctx, time.Duration(c.Timeout) * time.Second)
+    }
+    defer cancel()
+
+    cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+    cmd.Dir = c.Dir
+    if len(c.Env) > 0 { cmd.Env = append(os.Environ(), c.Env...) }
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    grace := time.Duration(c.KillGrace) * time.Second
+    if grace <= 0 { grace = DefaultKillGrace }
+    killGroup(cmd, grace, clock)
+
+    r := &RunResult{Start: clock.Now(), ExitCode: -1, Attempts: 1}
+    if c.Limits != nil {
+        if err := c.Limits.limit(cmd); err != nil {
+            r.Err = fmt.Errorf("limits: %v", err)
+            return r
+        }
+    }
+    r.Err = cmd.Run()
+    r.Duration = clock.Now().Sub(r.Start)
+    r.Stdout, r.Stderr = stdout.String(), stderr.String()
+    if cmd.ProcessState != nil && cmd.ProcessState.Exited() { r.ExitCode = cmd.ProcessState.ExitCode() }
+    switch {
+    case errors.Is(ctx.Err(), context.DeadlineExceeded):
+        r.Err = fmt.Errorf("timeout after %ds: %v", c.Timeout, r.Err)
+    case errors.Is(ctx.Err(), context.Canceled):
+        r.Err = fmt.Errorf("killed: %v", r.Err)
+    }
+    return r
+}
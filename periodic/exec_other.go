@@ -0,0 +1,18 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+// Kill a command, there are no process groups here
+//
+package periodic
+
+import (
+    "os/exec"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// killGroup kills the process of cmd once its context is done, its children
+// are not killed.
+func killGroup(cmd *exec.Cmd, grace time.Duration, clock schedule.Clock) {
+    cmd.WaitDelay = grace + time.Second
+}
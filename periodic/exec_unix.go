@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+// Kill the whole process group of a command
+//
+package periodic
+
+import (
+    "os/exec"
+    "syscall"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// killGroup runs cmd in a process group of its own.  Once its context is done,
+// the group gets SIGTERM, then SIGKILL after grace, so that the children of
+// the command don't outlive it.
+func killGroup(cmd *exec.Cmd, grace time.Duration, clock schedule.Clock) {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    cmd.Cancel = func() error {
+        pgid := cmd.Process.Pid
+        if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil { return err }
+        go func() {
+            clock.Sleep(grace)
+            syscall.Kill(-pgid, syscall.SIGKILL)
+        }()
+        return nil
+    }
+    // don't wait on the output of orphaned children past the grace period
+    cmd.WaitDelay = grace + time.Second
+}
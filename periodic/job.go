@@ -0,0 +1,193 @@
+// A named job of a PeriodicRunner, with its own schedule, command and log
+//
+package periodic
+
+import (
+    "context"
+    "log"
+    "log/slog"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// A Job starts at the configured time of day, then runs by interval, or runs
+// at the fire times of its cron expression, on the calendar of its timezone.
+type Job struct {
+    counter  int64
+    sched    schedule.Schedule
+    loc      *time.Location
+    overlap  Overlap
+    owner    string             // the job whose lock this one runs under, see lockOwners
+    catchUpPolicy CatchUp
+    blackoutPolicy BlackoutPolicy
+    runner   *PeriodicRunner
+    conf     JobConfig          // as configured, to tell a changed job on Reload
+    quit     chan struct{}      // closed once the job is stopped
+    records  *slog.Logger       // of the runs, nil in the plain log format
+    *JobConfig
+    *log.Logger
+
+    // the runs in flight, guarded by mu
+    mu       sync.Mutex
+    running  int
+    pendingAt time.Time         // of a queued run, see OverlapQueue
+    cancel   context.CancelFunc // kills the latest run
+    finished chan struct{}      // closed once the latest run completes
+    last     *RunResult         // of the latest completed run
+    history  []RunInfo          // the recent runs, up to historySize
+    next     time.Time          // the next scheduled run
+    paused   bool               // no scheduled runs, see Pause
+    successor *Job              // the job rescheduling this one, see Reload
+    handover chan *held         // the lock held by the job this one reschedules
+    runs     sync.WaitGroup     // the runs in flight
+    upstream map[string]bool    // the upstream jobs completed since the latest run, if ok
+    deferred bool               // a run is deferred until a blackout closes
+}
+
+// runStatus is sent to the PeriodicRunner on each completed run.
+type runStatus struct {
+    job    *Job
+    idx    int
+    result *RunResult // nil if there is no command to execute
+}
+
+// newJob builds the job of a validated config, running under the lock of the
+// job named owner.  The run counter goes on from the state of p.
+func newJob(p *PeriodicRunner, conf JobConfig, owner string) (*Job, error) {
+    c := &JobConfig{}
+    *c = conf
+    logger, records, err := p.newLogger(c.LogFile, c.Name)
+    if err != nil { return nil, err }
+
+    sched, loc, err := c.schedule()
+    if err != nil { return nil, err }
+    if c.Interval <= 0 { c.Interval = 86400 }
+
+    overlap, err := ParseOverlap(c.Overlap)
+    if err != nil { return nil, err }
+    catchUp, err := ParseCatchUp(c.CatchUp)
+    if err != nil { return nil, err }
+    blackout, err := ParseBlackoutPolicy(c.Blackout)
+    if err != nil { return nil, err }
+
+    return &Job{counter: p.state.Job(c.Name).Counter, sched: sched, loc: loc, overlap: overlap,
+        catchUpPolicy: catchUp, blackoutPolicy: blackout, owner: owner, runner: p, conf: conf, quit: make(chan struct{}), records: records, JobConfig: c, Logger: logger}, nil
+}
+
+// run executes the command, retried as configured, it's killed if ctx is
+// canceled.  The completed run is reported to the runner, and to the jobs
+// after this one.
+func (j *Job) run(ctx context.Context) {
+    idx, r := j.runRecorded(ctx)
+    j.completed(r == nil || r.Err == nil)
+    j.runner.done <- runStatus{j, idx, r}
+}
+
+// runRecorded executes the command, retried as configured, and records the
+// run in the log, the state, the history and the metrics.  It returns the
+// index and the result of the run, nil if there is no command.
+func (j *Job) runRecorded(ctx context.Context) (int, *RunResult) {
+    idx := int(atomic.AddInt64(&j.counter, 1))
+    start := j.runner.Clock.Now()
+    j.Printf("[%d] PeriodicRunner running at %v ... ...", idx, start)
+    var r *RunResult
+    if len(j.Command) > 0 {
+        r = j.retry(ctx, idx)
+        j.mu.Lock()
+        j.last = r
+        j.mu.Unlock()
+    }
+    j.record(idx, r)
+    j.remember(idx, start, r)
+    j.measure(start, r)
+    return idx, r
+}
+
+// LastResult returns the result of the latest completed run, after all its
+// retries, or nil if there is none yet.
+func (j *Job) LastResult() *RunResult {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    return j.last
+}
+
+// nextFire returns the first fire time after t, zero if none, computed on
+// the calendar of the job's timezone.
+func (j *Job) nextFire(t time.Time) time.Time {
+    return j.sched.Next(t.In(j.loc))
+}
+
+// worker runs at each fire time by a drift-free schedule.Scheduler, which
+// realigns onto the wall clock after a clock jump, until the job stops.  A job
+// running after others has no schedule of its own.
+func (j *Job) worker() {
+    if len(j.After) > 0 {
+        j.Printf("PeriodicRunner will run after %s", strings.Join(j.After, ", "))
+        return
+    }
+    s := &schedule.Scheduler{
+        Schedule: j.sched,
+        Location: j.loc,
+        Clock:    j.runner.Clock,
+        OnNext:   func(next time.Time) {
+            j.mu.Lock()
+            j.next = next
+            j.mu.Unlock()
+            nextRun.Set(float64(next.Unix()), j.Name)
+            j.Printf("PeriodicRunner will run at %v", next)
+        },
+        OnJump:   func(d time.Duration) { j.Printf("clock jumped by %v, realign on the wall clock", d) },
+    }
+    scheduled := func(at time.Time) {
+        j.handled(at)
+        j.mu.Lock()
+        paused := j.paused
+        j.mu.Unlock()
+        if paused {
+            j.Printf("skip run: paused")
+            return
+        }
+        j.dispatch(at, j.randomSplay(), func() { j.fire(at) })
+    }
+    if err := s.Run(j.quit, scheduled); err != nil {
+        j.Printf("PeriodicRunner stops: %v", err)
+    }
+}
+
+// start electing the instance running the job if it owns its lock; as the runner
+// starts, if initial, it catches up the missed runs and runs now if RunNow;
+// then it runs at the fire times of the schedule until the job stops.
+func (j *Job) start(initial bool) {
+    if j.runner.Locker != nil && j.owner == j.Name {
+        tried := make(chan struct{})
+        go j.elect(j.runner.Locker, tried)
+        <-tried
+    }
+    if initial && len(j.After) == 0 {
+        j.catchUp()
+        if j.runner.RunNow {
+            j.Printf("run now, then on schedule")
+            now := j.runner.Clock.Now()
+            j.dispatch(now, 0, func() { j.fire(now) })
+        }
+    }
+    j.worker()
+}
+
+// stop scheduling the job, its runs in flight go on.  j.runner.mu must be held.
+func (j *Job) stop() {
+    if !j.stopping() { close(j.quit) }
+}
+
+func (j *Job) stopping() bool {
+    select {
+    case <-j.quit:
+        return true
+    default:
+        return false
+    }
+}
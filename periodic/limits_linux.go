@@ -0,0 +1,66 @@
+// Resource limits of the commands, set by a re-executed runner
+//
+package periodic
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "syscall"
+)
+
+// LimitsSupported tells whether the resource limits are supported here.
+const LimitsSupported = true
+
+// limitsEnv passes the limits to the runner re-executed as a shim, which sets
+// them on itself then executes the command.  So they're in place before the
+// command starts, which setting them from outside after fork can't ensure.
+const limitsEnv = "PERIODIC_RUNNER_LIMITS"
+
+func init() {
+    spec, ok := os.LookupEnv(limitsEnv)
+    if !ok { return }
+    os.Unsetenv(limitsEnv)
+    var l LimitsConfig
+    if _, err := fmt.Sscanf(spec, "%d %d %d", &l.CPU, &l.Memory, &l.OpenFiles); err != nil || len(os.Args) < 3 {
+        fmt.Fprintf(os.Stderr, "%s=%q: bad limits\n", limitsEnv, spec)
+        os.Exit(127)
+    }
+    if err := l.setrlimit(); err != nil {
+        fmt.Fprintf(os.Stderr, "limits: %v\n", err)
+        os.Exit(127)
+    }
+    err := syscall.Exec(os.Args[1], os.Args[2:], os.Environ())
+    fmt.Fprintf(os.Stderr, "exec %s: %v\n", os.Args[1], err)
+    os.Exit(127)
+}
+
+// setrlimit sets the limits on the current process.
+func (l *LimitsConfig) setrlimit() error {
+    set := func(resource int, cur, max uint64) error {
+        return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: cur, Max: max})
+    }
+    // SIGXCPU at the soft CPU limit, SIGKILL a second later
+    if l.CPU > 0 {
+        if err := set(syscall.RLIMIT_CPU, uint64(l.CPU), uint64(l.CPU) + 1); err != nil { return err }
+    }
+    if l.Memory > 0 {
+        if err := set(syscall.RLIMIT_AS, uint64(l.Memory) << 20, uint64(l.Memory) << 20); err != nil { return err }
+    }
+    if l.OpenFiles > 0 {
+        if err := set(syscall.RLIMIT_NOFILE, uint64(l.OpenFiles), uint64(l.OpenFiles)); err != nil { return err }
+    }
+    return nil
+}
+
+// limit cmd, not started yet, by running it through the re-executed runner.
+func (l *LimitsConfig) limit(cmd *exec.Cmd) error {
+    if cmd.Err != nil { return nil } // Start reports it
+    self, err := os.Executable()
+    if err != nil { return err }
+    cmd.Args = append([]string{self, cmd.Path}, cmd.Args...)
+    cmd.Path = self
+    if cmd.Env == nil { cmd.Env = os.Environ() }
+    cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d %d %d", limitsEnv, l.CPU, l.Memory, l.OpenFiles))
+    return nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+// Resource limits of the commands, unsupported here
+//
+package periodic
+
+import (
+    "errors"
+    "os/exec"
+)
+
+// LimitsSupported tells whether the resource limits are supported here.
+const LimitsSupported = false
+
+func (l *LimitsConfig) limit(cmd *exec.Cmd) error {
+    return errors.New("resource limits are supported on linux only")
+}
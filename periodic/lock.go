@@ -0,0 +1,143 @@
+// Locks electing the instance that runs a job among redundant PeriodicRunners
+//
+package periodic
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// A Locker elects, per job, the single instance running it among the
+// PeriodicRunners sharing its locks, e.g. on several hosts for redundancy.
+type Locker interface {
+    // TryLock acquires the lock named name without blocking, the Lease is nil
+    // if another instance holds it.
+    TryLock(name string) (Lease, error)
+}
+
+// A Lease is a lock held until Unlock, or until it's lost.
+type Lease interface {
+    // Lost is closed once the lock is lost, another instance may hold it then.
+    Lost() <-chan struct{}
+    Unlock() error
+}
+
+// LockRetryInterval is how often a job retries to acquire its lock, and how
+// often a FileLocker checks that a lock is still held.
+var LockRetryInterval = 5 * time.Second
+
+// lockName is the name of the lock of the job: the one of its owner, the
+// first job of its DAG component without dependencies, see lockOwners.
+func (j *Job) lockName() string {
+    if j.owner == "" { return "periodic" }
+    return j.owner
+}
+
+// a held lock and the context of the runs under it, canceled once it's lost
+type held struct {
+    lease  Lease
+    ctx    context.Context
+    cancel context.CancelFunc
+}
+
+// elect holds the lock of the job while it's scheduled, retrying until it's
+// acquired, and again once it's lost; tried is closed after the first try.
+// Only the owner of a lock elects, the jobs after it run under its lock.  The
+// runs under the lock are killed if it's lost; once the job stops, the lock
+// is released after the runs in flight under it complete, or handed over to
+// the job rescheduling it, see Reload.
+func (j *Job) elect(locker Locker, tried chan<- struct{}) {
+    once := sync.Once{}
+    name := j.lockName()
+    var h *held
+    if j.handover != nil {
+        if h = <-j.handover; h != nil { j.Printf("lock %s handed over on reload", name) }
+    }
+    for {
+        if h == nil {
+            lease, err := locker.TryLock(name)
+            if err != nil { j.Printf("lock %s: %v", name, err) }
+            if lease == nil {
+                once.Do(func() {
+                    j.Printf("lock %s is held elsewhere, skip the runs until acquired", name)
+                    close(tried)
+                })
+                select {
+                case <-j.quit:
+                    j.handOver(nil)
+                    return
+                case <-j.runner.Clock.After(LockRetryInterval):
+                    continue
+                }
+            }
+            ctx, cancel := context.WithCancel(j.runner.ctx)
+            h = &held{lease, ctx, cancel}
+            j.Printf("lock %s acquired, run here", name)
+        }
+        j.runner.holdLease(name, h.ctx)
+        once.Do(func() { close(tried) })
+
+        select {
+        case <-j.quit:
+            if j.handOver(h) { return }
+            j.waitRuns()
+            j.runner.dropLease(name, h.ctx)
+            h.cancel()
+            if err := h.lease.Unlock(); err != nil { j.Printf("unlock %s: %v", name, err) }
+            return
+        case <-h.lease.Lost():
+            j.runner.dropLease(name, h.ctx)
+            j.Printf("lock %s lost, kill the runs in flight and run elsewhere", name)
+            h.cancel()
+            h.lease.Unlock()
+            h = nil
+        }
+    }
+}
+
+// handOver the held lock, nil if not held, to the job rescheduling the
+// stopped one, if any, and tells if there is one.
+func (j *Job) handOver(h *held) bool {
+    j.mu.Lock()
+    successor := j.successor
+    j.mu.Unlock()
+    if successor == nil || successor.handover == nil { return false }
+    successor.handover <- h
+    return true
+}
+
+// waitRuns waits for the runs in flight of the job, and of the jobs running
+// under its lock.
+func (j *Job) waitRuns() {
+    j.runs.Wait()
+    j.runner.mu.Lock()
+    jobs := append([]*Job(nil), j.runner.jobs...)
+    j.runner.mu.Unlock()
+    for _, job := range jobs {
+        if job != j && job.lockName() == j.lockName() { job.runs.Wait() }
+    }
+}
+
+// holdLease records ctx as the context of the runs under the lock named name.
+func (p *PeriodicRunner) holdLease(name string, ctx context.Context) {
+    p.leaseMu.Lock()
+    defer p.leaseMu.Unlock()
+    p.leases[name] = ctx
+}
+
+// dropLease forgets the context ctx of the lock named name, unless replaced.
+func (p *PeriodicRunner) dropLease(name string, ctx context.Context) {
+    p.leaseMu.Lock()
+    defer p.leaseMu.Unlock()
+    if p.leases[name] == ctx { delete(p.leases, name) }
+}
+
+// runContext returns the context of a new run: the one of the runner, or of
+// the lease if the job is locked.  It's nil if the lock isn't held.
+func (j *Job) runContext() context.Context {
+    if j.runner.Locker == nil { return j.runner.ctx }
+    j.runner.leaseMu.Lock()
+    defer j.runner.leaseMu.Unlock()
+    return j.runner.leases[j.lockName()]
+}
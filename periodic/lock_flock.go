@@ -0,0 +1,92 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+// The flock backend of the job locks
+//
+package periodic
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+    "syscall"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// A FileLocker locks the files <Dir>/<name>.lock by flock(2), e.g. on a
+// filesystem shared by the hosts.  A lock is released once its process dies.
+type FileLocker struct {
+    Dir   string
+    Clock schedule.Clock // of the lease checks, schedule.Real if nil
+}
+
+// NewFileLocker returns the FileLocker of the directory dir.
+func NewFileLocker(dir string) (*FileLocker, error) {
+    fi, err := os.Stat(dir)
+    if err != nil { return nil, err }
+    if !fi.IsDir() { return nil, fmt.Errorf("%s: not a directory", dir) }
+    return &FileLocker{Dir: dir}, nil
+}
+
+func (l *FileLocker) TryLock(name string) (Lease, error) {
+    path := filepath.Join(l.Dir, name + ".lock")
+    fp, err := os.OpenFile(path, os.O_RDWR | os.O_CREATE, 0666)
+    if err != nil { return nil, err }
+    if err := syscall.Flock(int(fp.Fd()), syscall.LOCK_EX | syscall.LOCK_NB); err != nil {
+        fp.Close()
+        if err == syscall.EWOULDBLOCK { return nil, nil }
+        return nil, err
+    }
+
+    // tell who holds the lock, for the humans
+    host, _ := os.Hostname()
+    fp.Truncate(0)
+    clock := l.Clock
+    if clock == nil { clock = schedule.Real }
+    fmt.Fprintf(fp, "%s %d %s\n", host, os.Getpid(), clock.Now().Format(time.RFC3339))
+
+    f := &fileLease{fp: fp, path: path, clock: clock, lost: make(chan struct{}), done: make(chan struct{})}
+    go f.watch()
+    return f, nil
+}
+
+// A fileLease is lost once its file is removed or replaced, since another
+// instance can lock the new file then.
+type fileLease struct {
+    fp    *os.File
+    path  string
+    clock schedule.Clock
+    lost  chan struct{}
+    done  chan struct{}
+    once  sync.Once
+}
+
+func (f *fileLease) watch() {
+    for {
+        select {
+        case <-f.done:
+            return
+        case <-f.clock.After(LockRetryInterval):
+        }
+        held, err := f.fp.Stat()
+        if err != nil { continue }
+        if cur, err := os.Stat(f.path); err != nil || !os.SameFile(held, cur) {
+            close(f.lost)
+            return
+        }
+    }
+}
+
+func (f *fileLease) Lost() <-chan struct{} { return f.lost }
+
+func (f *fileLease) Unlock() error {
+    err := os.ErrClosed
+    f.once.Do(func() {
+        close(f.done)
+        syscall.Flock(int(f.fp.Fd()), syscall.LOCK_UN)
+        err = f.fp.Close()
+    })
+    return err
+}
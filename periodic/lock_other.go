@@ -0,0 +1,26 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+// The flock backend of the job locks, unsupported here
+//
+package periodic
+
+import (
+    "errors"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// A FileLocker locks files by flock(2), which this platform lacks.
+type FileLocker struct {
+    Dir   string
+    Clock schedule.Clock
+}
+
+// NewFileLocker fails, flock(2) is not supported on this platform.
+func NewFileLocker(dir string) (*FileLocker, error) {
+    return nil, errors.New("lock_dir: flock is not supported on this platform")
+}
+
+func (l *FileLocker) TryLock(name string) (Lease, error) {
+    return nil, errors.New("flock is not supported on this platform")
+}
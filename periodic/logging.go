@@ -0,0 +1,88 @@
+// Plain or structured logging of the PeriodicRunner and its jobs
+//
+package periodic
+
+import (
+    "fmt"
+    "io"
+    "log"
+    "log/slog"
+    "os"
+    "strings"
+)
+
+// The log formats of PeriodicConfig.LogFormat.
+const (
+    LogText   = "text"   // plain log lines, the default
+    LogJSON   = "json"   // one json record per line, by log/slog
+    LogLogfmt = "logfmt" // one key=value record per line, by log/slog
+)
+
+// openLog returns the writer of logFile, opened once for all the jobs sharing
+// it, stdout if logFile is empty.  p.mu must be held once the runner runs.
+func (p *PeriodicRunner) openLog(logFile string) (io.Writer, error) {
+    if w, ok := p.logs[logFile]; ok { return w, nil }
+    var w io.Writer = os.Stdout
+    if logFile != "" {
+        var rc RotateConfig
+        if p.LogRotate != nil { rc = *p.LogRotate }
+        f, err := OpenRotatingFile(logFile, rc)
+        if err != nil { return nil, err }
+        w = f
+    }
+    p.logs[logFile] = w
+    return w, nil
+}
+
+// newLogger returns the logger of the job named name, or of the runner if
+// name is empty, writing to logFile.  In a structured log format, it also
+// returns the logger of the run records; the messages of the plain logger
+// are records of their own then.
+func (p *PeriodicRunner) newLogger(logFile, name string) (*log.Logger, *slog.Logger, error) {
+    w, err := p.openLog(logFile)
+    if err != nil { return nil, nil, err }
+
+    var h slog.Handler
+    switch p.LogFormat {
+    case "", LogText:
+        prefix := "PeriodicRunner: "
+        if name != "" { prefix = "PeriodicRunner[" + name + "]: " }
+        return log.New(w, prefix, log.LstdFlags), nil, nil
+    case LogJSON:
+        h = slog.NewJSONHandler(w, nil)
+    case LogLogfmt:
+        h = slog.NewTextHandler(w, nil)
+    default:
+        return nil, nil, fmt.Errorf("log_format %q: expect text, json or logfmt", p.LogFormat)
+    }
+    records := slog.New(h)
+    if name != "" { records = records.With("job", name) }
+    return slog.NewLogLogger(records.Handler(), slog.LevelInfo), records, nil
+}
+
+// logResult writes the outcome and the output of the idx-th run to the log:
+// a single record in a structured log format, some lines otherwise.
+func (j *Job) logResult(idx int, r *RunResult) {
+    if j.records != nil {
+        attrs := []any{"run", idx, "command", j.Command, "attempt", r.Attempts,
+            "start", r.Start, "end", r.Start.Add(r.Duration), "duration", r.Duration,
+            "exit_code", r.ExitCode, "stdout", r.Stdout, "stderr", r.Stderr}
+        if r.Err != nil {
+            j.records.Error("run failed", append(attrs, "error", r.Err.Error())...)
+        } else {
+            j.records.Info("run succeeded", attrs...)
+        }
+        return
+    }
+
+    for _, out := range []struct{ name, text string }{{"stdout", r.Stdout}, {"stderr", r.Stderr}} {
+        for _, line := range strings.Split(strings.TrimRight(out.text, "\n"), "\n") {
+            if line != "" { j.Printf("[%d] %s: %s", idx, out.name, line) }
+        }
+    }
+    status := "ok"
+    if r.Err != nil { status = r.Err.Error() }
+    attempt := ""
+    if max := j.Retry.attempts(); max > 1 { attempt = fmt.Sprintf(" (attempt %d of %d)", r.Attempts, max) }
+    j.Printf("[%d] %q exit %d in %v%s: %s", idx, strings.Join(j.Command, " "), r.ExitCode, r.Duration, attempt, status)
+}
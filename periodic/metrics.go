@@ -0,0 +1,32 @@
+// Metrics of the PeriodicRunner jobs, see package metrics
+//
+package periodic
+
+import (
+    "time"
+
+    "github.com/xzturn/go-by-example/metrics"
+)
+
+// The metrics of all the jobs, labeled by job name.
+var (
+    runsTotal    = metrics.Default.Counter("periodic_runs_total", "Completed runs.", "job")
+    runFailures  = metrics.Default.Counter("periodic_run_failures_total", "Completed runs that failed, after their retries.", "job")
+    runsSkipped  = metrics.Default.Counter("periodic_runs_skipped_total", "Runs of the jobs after others skipped, since an upstream job failed or skipped, or the job is paused.", "job", "reason")
+    runDuration  = metrics.Default.Histogram("periodic_run_duration_seconds", "Duration of the completed runs, retries included.", nil, "job")
+    runsInFlight = metrics.Default.Gauge("periodic_runs_in_flight", "Runs started and not yet completed.", "job")
+    nextRun      = metrics.Default.Gauge("periodic_next_run_timestamp_seconds", "Unix time of the next scheduled run.", "job")
+    lastSuccess  = metrics.Default.Gauge("periodic_last_success_timestamp_seconds", "Unix time of the start of the latest successful run.", "job")
+)
+
+// measure the completed run started at start, its result r is nil if there
+// is no command to execute.
+func (j *Job) measure(start time.Time, r *RunResult) {
+    runsTotal.Inc(j.Name)
+    runDuration.Observe(j.runner.Clock.Now().Sub(start).Seconds(), j.Name)
+    if r != nil && r.Err != nil {
+        runFailures.Inc(j.Name)
+    } else {
+        lastSuccess.Set(float64(start.Unix()), j.Name)
+    }
+}
@@ -0,0 +1,115 @@
+// Overlap policy of a job whose run outlasts its interval
+//
+package periodic
+
+import (
+    "context"
+    "fmt"
+    "time"
+)
+
+// An Overlap policy tells what to do when a job fires while it's still running.
+type Overlap string
+
+const (
+    OverlapAllow   Overlap = "allow"   // start a concurrent run
+    OverlapSkip    Overlap = "skip"    // skip the new run, the default
+    OverlapQueue   Overlap = "queue"   // queue one pending run, skip further ones
+    OverlapReplace Overlap = "replace" // kill the running one and start fresh
+)
+
+// ParseOverlap returns the Overlap policy named s, OverlapSkip if s is empty.
+func ParseOverlap(s string) (Overlap, error) {
+    switch o := Overlap(s); o {
+    case "":
+        return OverlapSkip, nil
+    case OverlapAllow, OverlapSkip, OverlapQueue, OverlapReplace:
+        return o, nil
+    }
+    return OverlapSkip, fmt.Errorf("overlap %q: expect allow, skip, queue or replace", s)
+}
+
+// fire starts a run of the job scheduled at the given time, or not, according
+// to its overlap policy.  Nothing starts any more once the job stops.
+func (j *Job) fire(at time.Time) {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    if j.stopping() { return }
+    if j.runContext() == nil {
+        j.Printf("skip run: the lock %s is held elsewhere", j.lockName())
+        return
+    }
+
+    if j.running > 0 {
+        switch j.overlap {
+        case OverlapAllow:
+            j.Printf("overlap with %d running run(s)", j.running)
+        case OverlapSkip:
+            j.Printf("skip run: the previous one is still running")
+            return
+        case OverlapQueue:
+            if !j.pendingAt.IsZero() {
+                j.Printf("skip run: one is already queued")
+            } else {
+                j.Printf("queue run until the running one completes")
+                j.pendingAt = at
+            }
+            return
+        case OverlapReplace:
+            j.Printf("kill the running run for a fresh one")
+            j.cancel()
+            j.launch(j.finished, at)
+            return
+        }
+    }
+    j.launch(nil, at)
+}
+
+// launch starts a run once prev, if any, is closed, and records its scheduled
+// time in the state file.  Nothing starts if the lock of the job isn't held.
+// j.mu must be held.
+func (j *Job) launch(prev <-chan struct{}, at time.Time) {
+    parent := j.runContext()
+    if parent == nil {
+        j.Printf("skip run: the lock %s is held elsewhere", j.lockName())
+        return
+    }
+    j.handled(at)
+    ctx, cancel := context.WithCancel(parent)
+    finished := make(chan struct{})
+    j.runner.inflight.Add(1)
+    j.runs.Add(1)
+    j.running++
+    runsInFlight.Set(float64(j.running), j.Name)
+    j.cancel, j.finished = cancel, finished
+    go func() {
+        if prev != nil { <-prev }
+        j.run(ctx)
+        cancel()
+        close(finished)
+
+        // the run completes as one of the job rescheduling this one, if any
+        cur := j.lockLatest()
+        defer cur.mu.Unlock()
+        defer cur.runs.Done()
+        cur.running--
+        runsInFlight.Set(float64(cur.running), cur.Name)
+        if !cur.pendingAt.IsZero() && cur.running == 0 && !cur.stopping() && cur.runContext() != nil {
+            at := cur.pendingAt
+            cur.pendingAt = time.Time{}
+            cur.launch(nil, at)
+        }
+    }()
+}
+
+// lockLatest locks and returns the latest job rescheduling j on Reload, or j
+// itself if none, see takeOver.
+func (j *Job) lockLatest() *Job {
+    for {
+        j.mu.Lock()
+        if j.successor == nil { return j }
+        next := j.successor
+        j.mu.Unlock()
+        j = next
+    }
+}
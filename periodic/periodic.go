@@ -0,0 +1,287 @@
+// Package periodic runs jobs like crontab, each of them either
+//    Start at hhmmss and run by interval, or
+//    run at the times of a cron expression
+// on the calendar of its timezone, see schedule.Every and schedule.Cron.
+//
+package periodic
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// JobConfig is the json config of one job of a PeriodicRunner.
+type JobConfig struct {
+    Name      string  `json:"name"`
+    StartTime string  `json:"start_time"`
+    Interval  int     `json:"interval_in_seconds"`
+    LogFile   string  `json:"log_file"`
+    Cron      string  `json:"cron"` // if set, overrides start time and interval
+    Timezone  string  `json:"timezone"` // IANA name of the schedule's timezone, local if empty
+
+    // the window the scheduled runs are delayed within, so that the hosts
+    // sharing a schedule don't all run at once, and how: random (the
+    // default) or host, see SplayMode
+    Splay     int     `json:"splay_in_seconds"`
+    SplayMode string  `json:"splay_mode"`
+
+    // the command to execute on each run: the program and its arguments, the
+    // extra KEY=VALUE environment, working directory and per-run timeout, the
+    // grace period between SIGTERM and SIGKILL once timed out or killed,
+    // DefaultKillGrace if <= 0, and the resource limits (linux only)
+    Command   []string  `json:"command"`
+    Env       []string  `json:"env"`
+    Dir       string    `json:"dir"`
+    Timeout   int       `json:"timeout_in_seconds"`
+    KillGrace int       `json:"kill_grace_in_seconds"`
+    Limits    *LimitsConfig `json:"limits"`
+
+    // what to do when the job fires while still running: allow, skip (the
+    // default), queue or replace, see Overlap
+    Overlap   string    `json:"overlap"`
+
+    Retry     *RetryConfig  `json:"retry"` // retries of a failed run
+
+    // what to do on startup with the runs missed while the runner was down:
+    // skip (the default), once or all, see CatchUp.  It needs a state file.
+    CatchUp   string    `json:"catch_up"`
+
+    // the names of the jobs this one runs after: it has no schedule of its
+    // own, it runs once they all succeeded, and is skipped if one fails
+    After     []string  `json:"after"`
+
+    // what to do with the scheduled runs in a blackout window: skip (the
+    // default), defer or ignore, see BlackoutPolicy
+    Blackout  string    `json:"blackout"`
+}
+
+// PeriodicConfig is the json config of a PeriodicRunner: a list of named jobs,
+// or the fields of a single job at the top level as before jobs were supported.
+// The top level log file is the runner's log, and the default log of the jobs.
+type PeriodicConfig struct {
+    JobConfig
+    Jobs      []JobConfig  `json:"jobs"`
+    StateFile string       `json:"state_file"` // the run history, kept in memory if empty
+
+    // how long Shutdown waits for the runs in flight before killing them,
+    // DefaultShutdownGrace if <= 0
+    ShutdownGrace int      `json:"shutdown_grace_in_seconds"`
+
+    LogFormat string        `json:"log_format"` // text (the default), json or logfmt
+    LogRotate *RotateConfig `json:"log_rotate"` // of all the log files, never rotated if nil
+
+    // the shared directory of the job locks, see FileLocker; without it,
+    // every instance runs every job
+    LockDir   string        `json:"lock_dir"`
+
+    // the windows when the scheduled runs of all the jobs are skipped or
+    // deferred, per the blackout policy of each job
+    Blackouts []BlackoutConfig `json:"blackouts"`
+}
+
+// DefaultShutdownGrace is the grace period of Shutdown unless configured.
+const DefaultShutdownGrace = 30 * time.Second
+
+// ErrKilled is returned by Shutdown if runs had to be killed.
+var ErrKilled = errors.New("runs in flight killed after the grace period")
+
+func (c *JobConfig) ParseHourMinSec(hms string) (h, m, s int, e error) {
+    tmp := strings.Split(hms, ":")
+    if len(tmp) != 3 { e = errors.New("expect hh:mm:ss"); return }
+
+    h, e = strconv.Atoi(tmp[0])
+    if e != nil { return }
+    if h < 0 || h > 23 { e = errors.New("expect 00 <= hh <= 23"); return }
+
+    m, e = strconv.Atoi(tmp[1])
+    if e != nil { return }
+    if m < 0 || m > 59 { e = errors.New("expect 00 <= mm <= 59"); return }
+
+    s, e = strconv.Atoi(tmp[2])
+    if e != nil { return }
+    if s < 0 || s > 59 { e = errors.New("expect 00 <= ss <= 59"); return }
+
+    return
+}
+
+
+////////////////////////////////////////////////////////////////////////////////
+
+// PeriodicRunner schedules all its jobs in parallel, each on its own timer.
+type PeriodicRunner struct {
+    cfgFile  string
+    over     JobConfig          // the overrides of the single top level job
+    mu       sync.Mutex         // guards jobs and logs against Reload
+    jobs     []*Job
+    logs     map[string]io.Writer
+    state    *State
+    blackouts []*schedule.Window
+    leaseMu  sync.Mutex
+    leases   map[string]context.Context // of the runs under each held lock
+
+    done     chan runStatus     // the completed runs
+    quit     chan struct{}      // closed once Shutdown starts
+    ctx      context.Context    // of all the runs, canceled to kill them
+    kill     context.CancelFunc
+    inflight sync.WaitGroup     // the runs not yet logged as completed
+
+    // elects the instance running each job, set before Run; nil if the jobs
+    // run on every instance
+    Locker   Locker
+    // tells the time of the schedules, set before Run, schedule.Real by default
+    Clock    schedule.Clock
+    // if set before Run, each job runs at once, then on its schedule; a run
+    // in a blackout window goes by the blackout policy of the job
+    RunNow   bool
+
+    *PeriodicConfig
+    *log.Logger
+}
+
+// NewPeriodicRunner loads and validates the config from cfgFile by LoadConfig.
+// Without a list of jobs, the single top level job is run, and its startTime
+// (hh:mm:ss), intervalSec and cronSpec are overridden by the given ones if set.
+func NewPeriodicRunner(cfgFile, startTime string, intervalSec int, cronSpec string) (*PeriodicRunner, error) {
+    over := JobConfig{StartTime: startTime, Interval: intervalSec, Cron: cronSpec}
+    cfg, err := LoadConfig(cfgFile, over)
+    if err != nil { return nil, err }
+
+    p := &PeriodicRunner{cfgFile: cfgFile, over: over, logs: make(map[string]io.Writer), leases: make(map[string]context.Context), done: make(chan runStatus), quit: make(chan struct{}), Clock: schedule.Real, PeriodicConfig: cfg}
+    p.ctx, p.kill = context.WithCancel(context.Background())
+    if p.Logger, _, err = p.newLogger(cfg.LogFile, ""); err != nil { return nil, err }
+
+    if p.state, err = LoadState(cfg.StateFile); err != nil { return nil, err }
+    if p.blackouts, err = blackoutWindows(cfg); err != nil { return nil, err }
+    if cfg.LockDir != "" {
+        if p.Locker, err = NewFileLocker(cfg.LockDir); err != nil { return nil, err }
+    }
+    owners := lockOwners(jobConfigs(cfg))
+    for _, c := range jobConfigs(cfg) {
+        job, err := newJob(p, c, owners[c.Name])
+        if err != nil { return nil, err }
+        p.jobs = append(p.jobs, job)
+    }
+    return p, nil
+}
+
+// Run runs all the jobs in parallel, and logs each completed run.  It returns
+// once Shutdown is complete.
+func (p *PeriodicRunner) Run() {
+    if l, ok := p.Locker.(*FileLocker); ok && l.Clock == nil { l.Clock = p.Clock }
+    p.mu.Lock()
+    for _, job := range p.jobs { go job.start(true) }
+    p.mu.Unlock()
+    if p.cfgFile != "" { go p.watch(ConfigPollInterval) }
+
+    for st := range p.done {
+        st.job.Printf("[%d] work complete: %s", st.idx, st.result.status())
+        p.inflight.Done()
+    }
+}
+
+func (p *PeriodicRunner) stopping() bool {
+    select {
+    case <-p.quit:
+        return true
+    default:
+        return false
+    }
+}
+
+// wait for the runs in flight, up to d; it's true if they all completed.
+func (p *PeriodicRunner) wait(d time.Duration) bool {
+    c := make(chan struct{})
+    go func() {
+        p.inflight.Wait()
+        close(c)
+    }()
+    select {
+    case <-c:
+        return true
+    case <-p.Clock.After(d):
+        return false
+    }
+}
+
+// Shutdown stops scheduling new runs, waits for the runs in flight up to the
+// grace period, kills the ones still running, then flushes and closes the log
+// files.  It returns ErrKilled if some runs had to be killed.
+func (p *PeriodicRunner) Shutdown() error {
+    p.mu.Lock()
+    close(p.quit)
+    for _, job := range p.jobs { job.stop() }
+    grace := time.Duration(p.ShutdownGrace) * time.Second
+    p.mu.Unlock()
+    if grace <= 0 { grace = DefaultShutdownGrace }
+    p.Printf("shutting down, wait up to %v for the runs in flight", grace)
+    var err error
+    if !p.wait(grace) {
+        err = ErrKilled
+        p.Printf("kill the runs still in flight")
+        p.kill()
+        if !p.wait(grace) {
+            p.Printf("shutdown incomplete, runs still in flight")
+            return err
+        }
+    }
+    p.kill()
+    close(p.done)
+    p.Printf("shutdown complete")
+    p.closeLogs()
+    return err
+}
+
+// closeLogs flushes and closes the log files.
+func (p *PeriodicRunner) closeLogs() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    for _, w := range p.logs {
+        if f, ok := w.(*RotatingFile); ok {
+            f.Sync()
+            f.Close()
+        }
+    }
+}
+
+// RunOnce runs the job named name once now, instead of Run: with its
+// retries, timeout and logging, recorded in the state, once its lock is
+// acquired if locked.  The jobs after it don't run.  The run is killed once
+// ctx is canceled.  It returns the result of the run, nil if the job has no
+// command, and closes the log files.  The single unnamed top level job is
+// named "".
+func (p *PeriodicRunner) RunOnce(ctx context.Context, name string) (*RunResult, error) {
+    defer p.closeLogs()
+    job := p.Job(name)
+    if job == nil { return nil, fmt.Errorf("no job %q", name) }
+    if p.Locker != nil {
+        lease, err := p.Locker.TryLock(job.lockName())
+        if err != nil { return nil, err }
+        if lease == nil { return nil, fmt.Errorf("lock %s is held elsewhere", job.lockName()) }
+        defer lease.Unlock()
+
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithCancel(ctx)
+        defer cancel()
+        go func() {
+            select {
+            case <-lease.Lost():
+                job.Printf("lock %s lost, kill the run", job.lockName())
+                cancel()
+            case <-ctx.Done():
+            }
+        }()
+    }
+    _, r := job.runRecorded(ctx)
+    return r, nil
+}
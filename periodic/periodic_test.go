@@ -0,0 +1,153 @@
+package periodic
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+    _ "time/tzdata" // America/New_York, whatever the host has
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// eventually polls cond until it holds, or fails after a while.
+func eventually(t *testing.T, what string, cond func() bool) {
+    t.Helper()
+    for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+        if time.Now().After(deadline) { t.Fatalf("timed out waiting for %s", what) }
+    }
+}
+
+// runner returns the PeriodicRunner of cfg, its single job c named "job", and
+// its log file and state in a temporary directory, the state of the job set
+// to st.
+func runner(t *testing.T, cfg PeriodicConfig, c JobConfig, st JobState) *PeriodicRunner {
+    t.Helper()
+    dir := t.TempDir()
+    c.Name = "job"
+    cfg.LogFile, cfg.StateFile, cfg.Jobs = filepath.Join(dir, "run.log"), filepath.Join(dir, "state.json"), []JobConfig{c}
+    for path, v := range map[string]any{cfg.StateFile: &State{Jobs: map[string]*JobState{"job": &st}}, filepath.Join(dir, "periodic.json"): cfg} {
+        blob, err := json.Marshal(v)
+        if err != nil { t.Fatal(err) }
+        if err := os.WriteFile(path, blob, 0666); err != nil { t.Fatal(err) }
+    }
+    p, err := NewPeriodicRunner(filepath.Join(dir, "periodic.json"), "", 0, "")
+    if err != nil { t.Fatal(err) }
+    return p
+}
+
+// fires runs the single job c on a FakeClock set to from, and returns the
+// scheduled times of its first n runs, the clock set to each one in turn.
+// The runs, their command included, are timed on the clock too.
+func fires(t *testing.T, c JobConfig, from time.Time, n int) []time.Time {
+    t.Helper()
+    p := runner(t, PeriodicConfig{}, c, JobState{})
+    clock := schedule.NewFakeClock(from)
+    p.Clock = clock
+    go p.Run()
+    defer p.Shutdown()
+
+    job := p.Job("job")
+    var got []time.Time
+    for i := 0; i < n; i++ {
+        var next time.Time
+        eventually(t, "the next run", func() bool {
+            st := job.Status()
+            if st.NextRun == nil || !st.NextRun.After(clock.Now()) { return false }
+            next = *st.NextRun
+            return true
+        })
+        clock.Set(next)
+        eventually(t, "the run", func() bool {
+            st := job.Status()
+            return len(st.Recent) == i + 1 && st.Running == 0
+        })
+        st := job.Status()
+        if !st.Recent[0].Start.Equal(next) { t.Fatalf("ran at %v, want %v", st.Recent[0].Start, next) }
+        got = append(got, *st.LastRun)
+    }
+    return got
+}
+
+func mustParse(t *testing.T, loc *time.Location, v string) time.Time {
+    t.Helper()
+    ts, err := time.ParseInLocation("2006-01-02 15:04:05 MST", v, loc)
+    if err != nil { t.Fatal(err) }
+    return ts
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// On 2026-03-08 02:00 EST jumps to 03:00 EDT in New York, on 2026-11-01 02:00
+// EDT falls back to 01:00 EST.
+func TestPeriodicRunnerFires(t *testing.T) {
+    ny, err := time.LoadLocation("America/New_York")
+    if err != nil { t.Fatal(err) }
+    tests := []struct {
+        name string
+        job  JobConfig
+        loc  *time.Location
+        from string
+        want []string
+    }{
+        {"midnight, cron hourly", JobConfig{Cron: "0 * * * *", Timezone: "UTC", Command: []string{"true"}}, time.UTC, "2026-12-31 22:30:00 UTC",
+            []string{"2026-12-31 23:00:00 UTC", "2027-01-01 00:00:00 UTC", "2027-01-01 01:00:00 UTC"}},
+        {"midnight, every 20m from 23:10", JobConfig{StartTime: "23:10:00", Interval: 1200, Timezone: "UTC"}, time.UTC, "2026-12-31 23:55:00 UTC",
+            []string{"2027-01-01 00:10:00 UTC", "2027-01-01 00:30:00 UTC", "2027-01-01 00:50:00 UTC"}},
+        {"spring forward, cron hourly", JobConfig{Cron: "0 * * * *", Timezone: "America/New_York"}, ny, "2026-03-08 00:30:00 EST",
+            []string{"2026-03-08 01:00:00 EST", "2026-03-08 03:00:00 EDT", "2026-03-08 04:00:00 EDT"}},
+        {"fall back, every 30m", JobConfig{StartTime: "00:00:00", Interval: 1800, Timezone: "America/New_York"}, ny, "2026-11-01 00:45:00 EDT",
+            []string{"2026-11-01 01:00:00 EDT", "2026-11-01 01:30:00 EDT", "2026-11-01 02:00:00 EST", "2026-11-01 02:30:00 EST"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := fires(t, tt.job, mustParse(t, tt.loc, tt.from), len(tt.want))
+            for i, v := range tt.want {
+                if want := mustParse(t, tt.loc, v); !got[i].Equal(want) { t.Fatalf("run %d at %v, want %v", i, got[i], want) }
+            }
+        })
+    }
+}
+
+// The blackout 01:00-03:00 UTC drops the missed fires in it before catching up.
+func TestCatchUpBlackout(t *testing.T) {
+    cfg := PeriodicConfig{Blackouts: []BlackoutConfig{{From: "01:00:00", To: "03:00:00", Timezone: "UTC"}}}
+    last := mustParse(t, time.UTC, "2026-10-18 23:00:00 UTC")
+    missed := times(t, "2026-10-19 00:00:00 UTC", "2026-10-19 01:00:00 UTC", "2026-10-19 02:00:00 UTC", "2026-10-19 03:00:00 UTC")
+    tests := []struct {
+        policy string
+        want   []time.Time
+    }{
+        {"skip", times(t, "2026-10-19 00:00:00 UTC", "2026-10-19 03:00:00 UTC")},
+        {"defer", times(t, "2026-10-19 00:00:00 UTC", "2026-10-19 01:00:00 UTC", "2026-10-19 03:00:00 UTC")},
+        {"ignore", missed},
+    }
+    for _, tt := range tests {
+        t.Run(tt.policy, func(t *testing.T) {
+            p := runner(t, cfg, JobConfig{Cron: "0 * * * *", Timezone: "UTC", Blackout: tt.policy}, JobState{LastFire: last})
+            job := p.Job("job")
+            if got := job.unblocked(job.missed(last, missed[len(missed) - 1])); !reflect.DeepEqual(got, tt.want) {
+                t.Fatalf("got %v, want %v", got, tt.want)
+            }
+        })
+    }
+
+    // once runs the latest missed fire out of the blackout, of 00:00 and 02:00
+    p := runner(t, cfg, JobConfig{Cron: "0 */2 * * *", Timezone: "UTC", CatchUp: "once"}, JobState{LastFire: last})
+    p.Clock = schedule.NewFakeClock(mustParse(t, time.UTC, "2026-10-19 03:30:00 UTC"))
+    go p.Run()
+    defer p.Shutdown()
+    job := p.Job("job")
+    eventually(t, "the run", func() bool { return len(job.Status().Recent) == 1 })
+    want := mustParse(t, time.UTC, "2026-10-19 00:00:00 UTC")
+    if st := job.Status(); !st.LastRun.Equal(want) { t.Fatalf("caught up %v, want %v", st.LastRun, want) }
+}
+
+func times(t *testing.T, values ...string) []time.Time {
+    t.Helper()
+    var ts []time.Time
+    for _, v := range values { ts = append(ts, mustParse(t, time.UTC, v)) }
+    return ts
+}
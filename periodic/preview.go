@@ -0,0 +1,120 @@
+// Dry-run preview of the fire times of the PeriodicRunner jobs
+//
+package periodic
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// schedule returns the schedule of the job and the timezone of its calendar:
+// its cron, or else every interval from its start time, an empty start time
+// is 00:00:00 and an empty interval 86400s.  A host splay offsets it, a random
+// one is left to each run.
+func (c *JobConfig) schedule() (schedule.Schedule, *time.Location, error) {
+    var err error
+    h, m, s := 0, 0, 0
+    if c.StartTime != "" {
+        if h, m, s, err = c.ParseHourMinSec(c.StartTime); err != nil { return nil, nil, err }
+    }
+    interval := c.Interval
+    if interval <= 0 { interval = 86400 }
+
+    var sched schedule.Schedule = schedule.Every{Hour: h, Minute: m, Second: s, Interval: time.Duration(interval) * time.Second}
+    if c.Cron != "" {
+        if sched, err = schedule.ParseCron(c.Cron); err != nil { return nil, nil, err }
+    }
+
+    if c.Splay > 0 && SplayMode(c.SplayMode) == SplayHost {
+        sched = schedule.Offset{Schedule: sched, Delay: hostSplay(c.Name, time.Duration(c.Splay) * time.Second)}
+    }
+
+    loc := time.Local
+    if c.Timezone != "" {
+        if loc, err = time.LoadLocation(c.Timezone); err != nil { return nil, nil, err }
+    }
+    return sched, loc, nil
+}
+
+// describe the schedule sched of the job.
+func (c *JobConfig) describe(sched schedule.Schedule) string {
+    if len(c.After) > 0 { return "after " + strings.Join(c.After, ", ") }
+    splay := ""
+    if o, ok := sched.(schedule.Offset); ok {
+        sched, splay = o.Schedule, fmt.Sprintf(", splayed by %v", o.Delay)
+    } else if c.Splay > 0 {
+        splay = fmt.Sprintf(", splayed at random within %ds", c.Splay)
+    }
+    if cron, ok := sched.(*schedule.Cron); ok { return "cron " + cron.String() + splay }
+    e := sched.(schedule.Every)
+    return fmt.Sprintf("every %v from %02d:%02d:%02d%s", e.Interval, e.Hour, e.Minute, e.Second, splay)
+}
+
+// A Preview lists the next fire times of a job, and what looks wrong with its
+// schedule.
+type Preview struct {
+    Job      string
+    Schedule string
+    Timezone string      // of the calendar of the schedule
+    Fires    []time.Time // empty for a job running after others
+    Notes    []string    // per fire time, why it doesn't run then, if so
+    Warnings []string
+}
+
+// PreviewConfig computes the next n fire times after from of each job of a
+// validated cfg, in loc, or in the timezone of each job if loc is nil, and
+// notes the ones in a blackout window.  It warns of the schedules firing less
+// than n times, never included, and of the ones firing twice within minGap,
+// if > 0.  Nothing is run.
+func PreviewConfig(cfg *PeriodicConfig, from time.Time, n int, loc *time.Location, minGap time.Duration) ([]Preview, error) {
+    windows, err := blackoutWindows(cfg)
+    if err != nil { return nil, err }
+    var previews []Preview
+    for _, c := range jobConfigs(cfg) {
+        sched, jobLoc, err := c.schedule()
+        if err != nil { return nil, err }
+        p := Preview{Job: c.Name, Schedule: c.describe(sched), Timezone: jobLoc.String()}
+        if len(c.After) > 0 {
+            previews = append(previews, p)
+            continue
+        }
+
+        gap := time.Duration(0)
+        for t := from; len(p.Fires) < n; {
+            next := sched.Next(t.In(jobLoc))
+            if next.IsZero() { break }
+            if len(p.Fires) > 0 && (gap == 0 || next.Sub(t) < gap) { gap = next.Sub(t) }
+            t = next
+            if loc != nil { next = next.In(loc) }
+            p.Fires = append(p.Fires, next)
+            p.Notes = append(p.Notes, blackoutNote(windows, next, c.Blackout))
+        }
+        switch {
+        case len(p.Fires) == 0:
+            p.Warnings = append(p.Warnings, "never fires")
+        case len(p.Fires) < n:
+            p.Warnings = append(p.Warnings, fmt.Sprintf("fires only %d more time(s)", len(p.Fires)))
+        }
+        if minGap > 0 && gap > 0 && gap < minGap {
+            p.Warnings = append(p.Warnings, fmt.Sprintf("fires %v apart, more often than every %v", gap, minGap))
+        }
+        previews = append(previews, p)
+    }
+    return previews, nil
+}
+
+// blackoutNote tells what a job of the given blackout policy does at the fire
+// time t, "" if it runs.
+func blackoutNote(windows []*schedule.Window, t time.Time, policy string) string {
+    end, in := blackoutEnd(windows, t)
+    switch b, _ := ParseBlackoutPolicy(policy); {
+    case !in || b == BlackoutIgnore:
+        return ""
+    case b == BlackoutDefer && !end.IsZero():
+        return "blackout, deferred to " + end.In(t.Location()).Format("Mon 2006-01-02 15:04:05 MST")
+    }
+    return "blackout, skipped"
+}
@@ -0,0 +1,146 @@
+// Hot reload of the PeriodicRunner config on SIGHUP and on file change
+//
+package periodic
+
+import (
+    "os"
+    "reflect"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+// ConfigPollInterval is how often Run checks the config file for a change.
+const ConfigPollInterval = 5 * time.Second
+
+// jobConfigs returns the jobs of a validated cfg: the single top level job if
+// no list of jobs is configured, or the listed ones with their default name
+// and log file.
+func jobConfigs(cfg *PeriodicConfig) []JobConfig {
+    if len(cfg.Jobs) == 0 { return []JobConfig{cfg.JobConfig} }
+
+    jobs := make([]JobConfig, len(cfg.Jobs))
+    for i, c := range cfg.Jobs {
+        if c.Name == "" && len(cfg.Jobs) > 1 { c.Name = "job" + strconv.Itoa(i) }
+        if c.LogFile == "" { c.LogFile = cfg.LogFile }
+        jobs[i] = c
+    }
+    return jobs
+}
+
+// Reload reads the config file again and applies it: the added jobs start,
+// the removed ones stop, the changed ones are stopped and started anew, and
+// the unchanged ones go on undisturbed.  The runs in flight of stopped jobs
+// complete, the ones of a rescheduled job go on as its own, see takeOver.  An invalid config is rejected and the running one stays active.
+// The log files and their format and rotation, the state file and the lock
+// directory can't be reloaded, they need a restart.
+func (p *PeriodicRunner) Reload() error {
+    cfg, err := LoadConfig(p.cfgFile, p.over)
+    if err != nil {
+        p.Printf("reload rejected, keep the running config: %v", err)
+        return err
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.stopping() { return nil }
+
+    windows, err := blackoutWindows(cfg)
+    if err != nil {
+        p.Printf("reload rejected, keep the running config: %v", err)
+        return err
+    }
+
+    // build the changed jobs first, so that a failure leaves the running ones
+    running, fresh := make(map[string]*Job), make(map[string]*Job)
+    for _, job := range p.jobs { running[job.Name] = job }
+    owners := lockOwners(jobConfigs(cfg))
+    for _, c := range jobConfigs(cfg) {
+        if job := running[c.Name]; job != nil && reflect.DeepEqual(job.conf, c) && job.owner == owners[c.Name] { continue }
+        job, err := newJob(p, c, owners[c.Name])
+        if err != nil {
+            p.Printf("reload rejected, keep the running config: job %q: %v", c.Name, err)
+            return err
+        }
+        fresh[c.Name] = job
+    }
+
+    var kept []string
+    for _, f := range []struct{ name string; changed bool }{{"log_file", cfg.LogFile != p.LogFile},
+        {"state_file", cfg.StateFile != p.StateFile}, {"log_format", cfg.LogFormat != p.LogFormat},
+        {"log_rotate", !reflect.DeepEqual(cfg.LogRotate, p.LogRotate)}, {"lock_dir", cfg.LockDir != p.LockDir}} {
+        if f.changed { kept = append(kept, f.name) }
+    }
+    if len(kept) > 0 { p.Printf("reload: keep the running %s until a restart", strings.Join(kept, ", ")) }
+    p.ShutdownGrace = cfg.ShutdownGrace
+    p.blackouts = windows
+
+    var started []*Job
+    p.jobs = nil
+    for _, c := range jobConfigs(cfg) {
+        job := running[c.Name]
+        delete(running, c.Name)
+        switch {
+        case fresh[c.Name] == nil:
+            p.jobs = append(p.jobs, job)
+            continue
+        case job != nil:
+            p.Printf("reload: reschedule job %q", c.Name)
+            if p.Locker != nil && job.owner == c.Name && owners[c.Name] == c.Name {
+                // its lock is handed over, not released then elected again
+                fresh[c.Name].handover = make(chan *held, 1)
+            }
+            fresh[c.Name].takeOver(job)
+        default:
+            p.Printf("reload: add job %q", c.Name)
+        }
+        job = fresh[c.Name]
+        p.jobs = append(p.jobs, job)
+        started = append(started, job)
+    }
+    for name, job := range running {
+        p.Printf("reload: remove job %q", name)
+        job.stop()
+        nextRun.Delete(name)
+    }
+    for _, job := range started { go job.start(false) }
+    p.Printf("reload %s: %d job(s), %d (re)started", p.cfgFile, len(p.jobs), len(started))
+    return nil
+}
+
+// takeOver the old job that j reschedules: j goes on with its run counter,
+// pause, history and runs in flight, which count for the overlap policy of j
+// and complete as runs of j.  The old job is stopped.  j isn't started yet.
+func (j *Job) takeOver(old *Job) {
+    old.mu.Lock()
+    defer old.mu.Unlock()
+    old.stop()
+    old.successor = j
+    if n := atomic.LoadInt64(&old.counter); n > j.counter { j.counter = n }
+    j.paused, j.history, j.last = old.paused, old.history, old.last
+    j.running, j.pendingAt, j.cancel, j.finished = old.running, old.pendingAt, old.cancel, old.finished
+    j.runs.Add(old.running)
+    old.running, old.pendingAt = 0, time.Time{}
+}
+
+// watch reloads the config file once it changes, polled every d.
+func (p *PeriodicRunner) watch(d time.Duration) {
+    stat := func() (time.Time, int64) {
+        fi, err := os.Stat(p.cfgFile)
+        if err != nil { return time.Time{}, -1 }
+        return fi.ModTime(), fi.Size()
+    }
+    mtime, size := stat()
+    for {
+        select {
+        case <-p.quit:
+            return
+        case <-p.Clock.After(d):
+        }
+        if t, n := stat(); n >= 0 && (!t.Equal(mtime) || n != size) {
+            mtime, size = t, n
+            p.Reload()
+        }
+    }
+}
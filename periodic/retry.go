@@ -0,0 +1,60 @@
+// Retry failed runs with exponential backoff and jitter
+//
+package periodic
+
+import (
+    "context"
+    "math"
+    "math/rand"
+    "time"
+)
+
+// RetryConfig is the json config of the retries of a failed run.  The n-th
+// retry waits
+//    min(initial_backoff * multiplier^(n-1), max_backoff) * (1 ± jitter)
+type RetryConfig struct {
+    MaxAttempts    int      `json:"max_attempts"`        // including the first, <= 1 for no retry
+    InitialBackoff int      `json:"initial_backoff_in_seconds"` // 1 if <= 0
+    Multiplier     float64  `json:"multiplier"`          // 2 if <= 0
+    MaxBackoff     int      `json:"max_backoff_in_seconds"` // no limit if <= 0
+    Jitter         float64  `json:"jitter"`              // randomized fraction of the backoff, in [0, 1]
+}
+
+// attempts is 1 if no retry is configured.
+func (c *RetryConfig) attempts() int {
+    if c == nil || c.MaxAttempts < 1 { return 1 }
+    return c.MaxAttempts
+}
+
+// backoff to wait before the n-th retry.
+func (c *RetryConfig) backoff(n int) time.Duration {
+    mult := c.Multiplier
+    if mult <= 0 { mult = 2 }
+    initial := c.InitialBackoff
+    if initial <= 0 { initial = 1 }
+    d := float64(initial) * math.Pow(mult, float64(n - 1))
+    if c.MaxBackoff > 0 { d = math.Min(d, float64(c.MaxBackoff)) }
+    jitter := math.Max(0, math.Min(c.Jitter, 1))
+    d *= 1 + jitter * (2 * rand.Float64() - 1)
+    return time.Duration(d * float64(time.Second))
+}
+
+// retry executes the command until it succeeds, or up to the configured
+// attempts, or until ctx is canceled.  It returns the last result.
+func (j *Job) retry(ctx context.Context, idx int) *RunResult {
+    max := j.Retry.attempts()
+    for attempt := 1; ; attempt++ {
+        r := j.execute(ctx, j.runner.Clock)
+        r.Attempts = attempt
+        j.logResult(idx, r)
+        if r.Err == nil || attempt >= max || ctx.Err() != nil { return r }
+
+        d := j.Retry.backoff(attempt)
+        j.Printf("[%d] retry in %v: attempt %d of %d", idx, d, attempt + 1, max)
+        select {
+        case <-j.runner.Clock.After(d):
+        case <-ctx.Done():
+            return r
+        }
+    }
+}
@@ -0,0 +1,166 @@
+// Size- and age-based rotation of the log files
+//
+package periodic
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// RotateConfig is the json config of the log file rotation.  A rotated file
+// is renamed with the time of the rotation appended, e.g. run.log.20060102T150405.
+type RotateConfig struct {
+    MaxSize    int  `json:"max_size_in_mb"`    // rotate once the file would grow over, no limit if <= 0
+    MaxAge     int  `json:"max_age_in_hours"`  // rotate once the file was started that long ago, no limit if <= 0
+    MaxBackups int  `json:"max_backups"`       // the rotated files kept, all if <= 0
+    Retention  int  `json:"retention_in_days"` // the rotated files removed once older, kept if <= 0
+}
+
+const rotateSuffix = "20060102T150405"
+
+// A RotatingFile is an append-only log file rotated per its RotateConfig,
+// safe for concurrent use.
+type RotatingFile struct {
+    path    string
+    cfg     RotateConfig
+    mu      sync.Mutex
+    fp      *os.File
+    size    int64
+    started time.Time // of the current file, see start
+}
+
+// OpenRotatingFile opens path for appending, rotated per cfg.
+func OpenRotatingFile(path string, cfg RotateConfig) (*RotatingFile, error) {
+    f := &RotatingFile{path: path, cfg: cfg}
+    if err := f.open(); err != nil { return nil, err }
+    return f, nil
+}
+
+func (f *RotatingFile) open() error {
+    fp, err := os.OpenFile(f.path, os.O_APPEND | os.O_RDWR | os.O_CREATE, 0666)
+    if err != nil { return err }
+    fi, err := fp.Stat()
+    if err != nil {
+        fp.Close()
+        return err
+    }
+    f.fp, f.size, f.started = fp, fi.Size(), time.Now()
+    if f.size > 0 { f.started = f.start() }
+    return nil
+}
+
+// the timestamps of the log lines, of the text format then of the json and
+// logfmt ones
+var (
+    textStamp = regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`)
+    isoStamp  = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+)
+
+// start tells when the current file, reopened, was started, so that its age
+// goes on across restarts: the time of its first log line, else of the latest
+// rotation, else now.
+func (f *RotatingFile) start() time.Time {
+    line, err := bufio.NewReader(io.NewSectionReader(f.fp, 0, 4096)).ReadString('\n')
+    if err == nil || err == io.EOF {
+        if m := textStamp.FindString(line); m != "" {
+            if t, err := time.ParseInLocation("2006/01/02 15:04:05", m, time.Local); err == nil { return t }
+        }
+        if m := isoStamp.FindString(line); m != "" {
+            if t, err := time.Parse(time.RFC3339Nano, m); err == nil { return t }
+        }
+    }
+    if rotated, err := f.backups(); err == nil && len(rotated) > 0 {
+        suffix := strings.TrimPrefix(rotated[0], f.path + ".")[:len(rotateSuffix)]
+        if t, err := time.ParseInLocation(rotateSuffix, suffix, time.Local); err == nil { return t }
+    }
+    return time.Now()
+}
+
+// Write appends p, rotating the file first if due.  A failed rotation goes on
+// writing to the current file.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if f.fp == nil { return 0, os.ErrClosed }
+
+    tooBig := f.cfg.MaxSize > 0 && f.size > 0 && f.size + int64(len(p)) > int64(f.cfg.MaxSize) << 20
+    tooOld := f.cfg.MaxAge > 0 && time.Since(f.started) > time.Duration(f.cfg.MaxAge) * time.Hour
+    if tooBig || tooOld {
+        if err := f.rotate(); err != nil { fmt.Fprintf(os.Stderr, "rotate %s: %v\n", f.path, err) }
+    }
+    n, err := f.fp.Write(p)
+    f.size += int64(n)
+    return n, err
+}
+
+// rotate renames the current file, opens a fresh one and prunes the backups.
+func (f *RotatingFile) rotate() error {
+    backup := f.path + "." + time.Now().Format(rotateSuffix)
+    if _, err := os.Stat(backup); err == nil { backup += fmt.Sprintf(".%d", time.Now().UnixNano()) }
+    if err := os.Rename(f.path, backup); err != nil { return err }
+    f.fp.Close()
+    if err := f.open(); err != nil {
+        // keep writing somewhere rather than nowhere
+        if fp, e := os.OpenFile(backup, os.O_APPEND | os.O_WRONLY, 0666); e == nil { f.fp = fp }
+        return err
+    }
+    return f.prune()
+}
+
+// backups returns the rotated files, newest first.
+func (f *RotatingFile) backups() ([]string, error) {
+    backups, err := filepath.Glob(f.path + ".*")
+    if err != nil { return nil, err }
+    var rotated []string
+    for _, b := range backups {
+        suffix := strings.TrimPrefix(b, f.path + ".")
+        if len(suffix) >= len(rotateSuffix) {
+            if _, err := time.Parse(rotateSuffix, suffix[:len(rotateSuffix)]); err == nil { rotated = append(rotated, b) }
+        }
+    }
+    sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+    return rotated, nil
+}
+
+// prune the backups beyond MaxBackups or older than Retention.
+func (f *RotatingFile) prune() error {
+    rotated, err := f.backups()
+    if err != nil { return err }
+    for i, b := range rotated {
+        old := false
+        if f.cfg.Retention > 0 {
+            fi, err := os.Stat(b)
+            old = err == nil && time.Since(fi.ModTime()) > time.Duration(f.cfg.Retention) * 24 * time.Hour
+        }
+        if (f.cfg.MaxBackups > 0 && i >= f.cfg.MaxBackups) || old {
+            if err := os.Remove(b); err != nil { return err }
+        }
+    }
+    return nil
+}
+
+// Sync flushes the current file to disk.
+func (f *RotatingFile) Sync() error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if f.fp == nil { return os.ErrClosed }
+    return f.fp.Sync()
+}
+
+// Close the current file, later writes fail.
+func (f *RotatingFile) Close() error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if f.fp == nil { return os.ErrClosed }
+    err := f.fp.Close()
+    f.fp = nil
+    return err
+}
@@ -0,0 +1,46 @@
+// Splay of the scheduled runs, spreading the hosts sharing a schedule
+//
+package periodic
+
+import (
+    "fmt"
+    "hash/fnv"
+    "math/rand"
+    "os"
+    "time"
+)
+
+// A SplayMode tells how a job delays its scheduled runs within its splay window.
+type SplayMode string
+
+const (
+    SplayRandom SplayMode = "random" // a new random delay for each run, the default
+    SplayHost   SplayMode = "host"   // a fixed delay derived from the hostname and the job name
+)
+
+// ParseSplayMode returns the SplayMode named s, SplayRandom if s is empty.
+func ParseSplayMode(s string) (SplayMode, error) {
+    switch m := SplayMode(s); m {
+    case "":
+        return SplayRandom, nil
+    case SplayRandom, SplayHost:
+        return m, nil
+    }
+    return SplayRandom, fmt.Errorf("splay_mode %q: expect random or host", s)
+}
+
+// hostSplay returns the delay of the job named name on this host within
+// window, in ms, the same on each run and restart.
+func hostSplay(name string, window time.Duration) time.Duration {
+    host, _ := os.Hostname()
+    h := fnv.New64a()
+    h.Write([]byte(host + "/" + name))
+    return time.Duration(h.Sum64() % uint64(window / time.Millisecond)) * time.Millisecond
+}
+
+// randomSplay returns the delay of the next scheduled run of the job, 0 unless
+// the job is splayed at random.
+func (j *Job) randomSplay() time.Duration {
+    if j.Splay <= 0 || SplayMode(j.SplayMode) == SplayHost { return 0 }
+    return time.Duration(rand.Int63n(int64(j.Splay) * 1000)) * time.Millisecond
+}
@@ -0,0 +1,207 @@
+// Persistent run history of the jobs, and catch-up of the runs missed while
+// the PeriodicRunner was down
+//
+package periodic
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "sync"
+    "time"
+)
+
+// A RunRecord is the persisted summary of a RunResult.
+type RunRecord struct {
+    Run      int        `json:"run"`
+    Start    time.Time  `json:"start"`
+    Duration string     `json:"duration"`
+    ExitCode int        `json:"exit_code"`
+    Attempts int        `json:"attempts"`
+    Error    string     `json:"error,omitempty"`
+}
+
+// JobState is the persisted run history of a job.
+type JobState struct {
+    Counter     int64      `json:"counter"`
+    LastFire    time.Time  `json:"last_fire"` // the latest fire time handled, run or skipped
+    LastSuccess *RunRecord `json:"last_success,omitempty"`
+    LastFailure *RunRecord `json:"last_failure,omitempty"`
+}
+
+// State is the on-disk state file of a PeriodicRunner, keyed by job name.
+// The zero path keeps the state in memory only.
+type State struct {
+    path string
+    mu   sync.Mutex
+    Jobs map[string]*JobState `json:"jobs"`
+}
+
+// LoadState reads the state file at path, a missing file is an empty state.
+func LoadState(path string) (*State, error) {
+    s := &State{path: path, Jobs: make(map[string]*JobState)}
+    if path == "" { return s, nil }
+    blob, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) { return s, nil }
+    if err != nil { return nil, err }
+    if err = json.Unmarshal(blob, s); err != nil { return nil, fmt.Errorf("state file %s: %v", path, err) }
+    if s.Jobs == nil { s.Jobs = make(map[string]*JobState) }
+    return s, nil
+}
+
+// Job returns a copy of the state of the named job.
+func (s *State) Job(name string) JobState {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if js := s.Jobs[name]; js != nil { return *js }
+    return JobState{}
+}
+
+// update the state of the named job by f, then save the state file.
+func (s *State) update(name string, f func(js *JobState)) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    js := s.Jobs[name]
+    if js == nil {
+        js = &JobState{}
+        s.Jobs[name] = js
+    }
+    f(js)
+    if s.path == "" { return nil }
+
+    // write a temporary file then rename, so a crash never leaves it truncated
+    blob, err := json.MarshalIndent(s, "", "    ")
+    if err != nil { return err }
+    tmp := s.path + ".tmp"
+    if err = ioutil.WriteFile(tmp, blob, 0666); err != nil { return err }
+    return os.Rename(tmp, s.path)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A CatchUp policy tells what to do on startup with the runs missed since the
+// latest recorded one.
+type CatchUp string
+
+const (
+    CatchUpSkip CatchUp = "skip" // forget them, the default
+    CatchUpOnce CatchUp = "once" // run once if any was missed
+    CatchUpAll  CatchUp = "all"  // run each missed one, in sequence
+)
+
+// maxCatchUp bounds the missed runs of CatchUpAll.
+const maxCatchUp = 1000
+
+// ParseCatchUp returns the CatchUp policy named s, CatchUpSkip if s is empty.
+func ParseCatchUp(s string) (CatchUp, error) {
+    switch c := CatchUp(s); c {
+    case "":
+        return CatchUpSkip, nil
+    case CatchUpSkip, CatchUpOnce, CatchUpAll:
+        return c, nil
+    }
+    return CatchUpSkip, fmt.Errorf("catch_up %q: expect skip, once or all", s)
+}
+
+// missed returns the fire times after last up to now, at most maxCatchUp.
+func (j *Job) missed(last, now time.Time) []time.Time {
+    var fires []time.Time
+    for t := j.nextFire(last); !t.IsZero() && !t.After(now) && len(fires) < maxCatchUp; t = j.nextFire(t) {
+        fires = append(fires, t)
+    }
+    return fires
+}
+
+// catchUp the runs missed since the latest recorded fire, per the policy, and
+// per the blackout policy of the job, both for the missed fires in a blackout
+// window and if now is in one.
+func (j *Job) catchUp() {
+    last := j.runner.state.Job(j.Name).LastFire
+    if last.IsZero() { return }
+    fires := j.missed(last, j.runner.Clock.Now())
+    if len(fires) == 0 { return }
+
+    if j.catchUpPolicy != CatchUpSkip {
+        runs := j.unblocked(fires)
+        if n := len(fires) - len(runs); n > 0 { j.Printf("skip %d run(s) missed in a blackout window", n) }
+        if len(runs) == 0 {
+            j.handled(fires[len(fires) - 1])
+            return
+        }
+        fires = runs
+    }
+    switch j.catchUpPolicy {
+    case CatchUpSkip:
+        j.Printf("skip %d run(s) missed since %v", len(fires), last)
+        j.handled(fires[len(fires) - 1])
+    case CatchUpOnce:
+        j.Printf("catch up once the %d run(s) missed since %v", len(fires), last)
+        j.dispatch(j.runner.Clock.Now(), 0, func() { j.fire(fires[len(fires) - 1]) })
+    case CatchUpAll:
+        j.Printf("catch up all the %d run(s) missed since %v", len(fires), last)
+        j.dispatch(j.runner.Clock.Now(), 0, func() { go j.catchUpAll(fires) })
+    }
+}
+
+// unblocked returns the missed fires the blackout policy of the job lets run:
+// those out of the blackout windows, and all if ignored, or the first of each
+// window if deferred, the others skipped as one is deferred already.
+func (j *Job) unblocked(fires []time.Time) []time.Time {
+    if j.blackoutPolicy == BlackoutIgnore { return fires }
+    var runs []time.Time
+    var deferred time.Time // until the end of the window
+    for _, at := range fires {
+        end, in := j.runner.blackout(at)
+        if in {
+            if j.blackoutPolicy == BlackoutSkip || end.IsZero() || end.Equal(deferred) { continue }
+            deferred = end
+        }
+        runs = append(runs, at)
+    }
+    return runs
+}
+
+// catchUpAll runs in sequence at each of the missed fire times, until the job
+// stops or its lock is held elsewhere.
+func (j *Job) catchUpAll(fires []time.Time) {
+    for _, at := range fires {
+        j.mu.Lock()
+        if j.stopping() {
+            j.mu.Unlock()
+            return
+        }
+        if j.runContext() == nil {
+            j.mu.Unlock()
+            j.Printf("stop catching up: the lock %s is held elsewhere", j.lockName())
+            return
+        }
+        j.launch(nil, at)
+        finished := j.finished
+        j.mu.Unlock()
+        <-finished
+    }
+}
+
+// handled records the fire time at in the state file once the job handled
+// it, run or skipped, so that it's never caught up after a restart.
+func (j *Job) handled(at time.Time) {
+    err := j.runner.state.update(j.Name, func(js *JobState) {
+        if at.After(js.LastFire) { js.LastFire = at }
+    })
+    if err != nil { j.Printf("save state: %v", err) }
+}
+
+// record the completed idx-th run in the state file.
+func (j *Job) record(idx int, r *RunResult) {
+    rec := &RunRecord{Run: idx, Start: j.runner.Clock.Now(), Duration: "0s", Attempts: 1}
+    if r != nil {
+        rec = &RunRecord{idx, r.Start, r.Duration.String(), r.ExitCode, r.Attempts, ""}
+        if r.Err != nil { rec.Error = r.Err.Error() }
+    }
+    err := j.runner.state.update(j.Name, func(js *JobState) {
+        if int64(idx) > js.Counter { js.Counter = int64(idx) }
+        if rec.Error == "" { js.LastSuccess = rec } else { js.LastFailure = rec }
+    })
+    if err != nil { j.Printf("save state: %v", err) }
+}
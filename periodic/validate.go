@@ -0,0 +1,264 @@
+// Strict validation of the PeriodicRunner config
+//
+package periodic
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/xzturn/go-by-example/schedule"
+)
+
+// A ConfigError lists every problem found in a config, each one prefixed by
+// the json path of its field, e.g. `jobs[1].cron: ...`.
+type ConfigError struct {
+    File     string
+    Problems []string
+}
+
+func (e *ConfigError) Error() string {
+    name := "config"
+    if e.File != "" { name += " " + e.File }
+    return fmt.Sprintf("%s: %d problem(s):\n    %s", name, len(e.Problems), strings.Join(e.Problems, "\n    "))
+}
+
+func (e *ConfigError) add(field, format string, args ...interface{}) {
+    e.Problems = append(e.Problems, field + ": " + fmt.Sprintf(format, args...))
+}
+
+// LoadConfig reads cfgFile strictly, unknown keys are errors, applies the
+// start time, interval and cron of over to the single top level job, then
+// validates the result.  An empty cfgFile is an empty config.  Any problem is
+// reported by a *ConfigError, all of them unless the file isn't valid JSON.
+func LoadConfig(cfgFile string, over JobConfig) (*PeriodicConfig, error) {
+    var cfg PeriodicConfig
+    e := &ConfigError{File: cfgFile}
+    if cfgFile != "" {
+        blob, err := ioutil.ReadFile(cfgFile)
+        if err != nil { return nil, &ConfigError{cfgFile, []string{err.Error()}} }
+        if !decode(e, blob, &cfg) { return nil, e }
+    }
+
+    if over.StartTime != "" || over.Interval > 0 || over.Cron != "" {
+        if len(cfg.Jobs) > 0 {
+            e.add("-s, -i, -e", "override the single top level job, but %d jobs are listed", len(cfg.Jobs))
+        } else {
+            if over.StartTime != "" { cfg.StartTime = over.StartTime }
+            if over.Interval > 0 { cfg.Interval = over.Interval }
+            if over.Cron != "" { cfg.Cron = over.Cron }
+        }
+    }
+    cfg.validate(e)
+    if len(e.Problems) > 0 { return nil, e }
+    return &cfg, nil
+}
+
+// decode blob into cfg leniently, so that the rest can still be validated,
+// reporting each unknown key and a mistyped value to e.  It fails if blob
+// isn't valid JSON.
+func decode(e *ConfigError, blob []byte, cfg *PeriodicConfig) bool {
+    var raw interface{}
+    if err := json.Unmarshal(blob, &raw); err != nil {
+        e.Problems = append(e.Problems, err.Error())
+        return false
+    }
+    unknownKeys(e, "", raw, reflect.TypeOf(cfg).Elem())
+
+    err := json.Unmarshal(blob, cfg)
+    if te, ok := err.(*json.UnmarshalTypeError); ok {
+        e.add(te.Field, "expect %v, got %s", te.Type, te.Value)
+    } else if err != nil {
+        e.Problems = append(e.Problems, err.Error())
+    }
+    return true
+}
+
+// unknownKeys reports the keys of the json value v at path which no field of
+// the type t decodes, down the nested objects and lists.
+func unknownKeys(e *ConfigError, path string, v interface{}, t reflect.Type) {
+    for t.Kind() == reflect.Ptr { t = t.Elem() }
+    switch v := v.(type) {
+    case []interface{}:
+        if t.Kind() != reflect.Slice { return }
+        for i, item := range v { unknownKeys(e, fmt.Sprintf("%s[%d]", path, i), item, t.Elem()) }
+    case map[string]interface{}:
+        if t.Kind() != reflect.Struct { return }
+        fields := make(map[string]reflect.Type)
+        jsonFields(fields, t)
+        keys := make([]string, 0, len(v))
+        for k := range v { keys = append(keys, k) }
+        sort.Strings(keys)
+        prefix := path
+        if prefix != "" { prefix += "." }
+        for _, k := range keys {
+            ft, ok := fields[strings.ToLower(k)]
+            if !ok {
+                e.add(prefix + k, "unknown key")
+                continue
+            }
+            unknownKeys(e, prefix + k, v[k], ft)
+        }
+    }
+}
+
+// jsonFields maps the lower case json names of the fields of the struct t,
+// and of its embedded ones, to their types.
+func jsonFields(fields map[string]reflect.Type, t reflect.Type) {
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+        switch {
+        case name == "-" || !f.IsExported():
+        case f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct:
+            jsonFields(fields, f.Type)
+        case name == "":
+            fields[strings.ToLower(f.Name)] = f.Type
+        default:
+            fields[strings.ToLower(name)] = f.Type
+        }
+    }
+}
+
+// Validate reports every problem of the config by a *ConfigError.
+func (c *PeriodicConfig) Validate() error {
+    e := &ConfigError{}
+    c.validate(e)
+    if len(e.Problems) > 0 { return e }
+    return nil
+}
+
+func (c *PeriodicConfig) validate(e *ConfigError) {
+    checkFile(e, "log_file", c.LogFile)
+    checkFile(e, "state_file", c.StateFile)
+    if c.StateFile != "" {
+        if _, err := LoadState(c.StateFile); err != nil { e.add("state_file", "%v", err) }
+    }
+    switch c.LogFormat {
+    case "", LogText, LogJSON, LogLogfmt:
+    default:
+        e.add("log_format", "%q: expect text, json or logfmt", c.LogFormat)
+    }
+    if r := c.LogRotate; r != nil {
+        if r.MaxSize < 0 { e.add("log_rotate.max_size_in_mb", "expect >= 0, got %d", r.MaxSize) }
+        if r.MaxAge < 0 { e.add("log_rotate.max_age_in_hours", "expect >= 0, got %d", r.MaxAge) }
+        if r.MaxBackups < 0 { e.add("log_rotate.max_backups", "expect >= 0, got %d", r.MaxBackups) }
+        if r.Retention < 0 { e.add("log_rotate.retention_in_days", "expect >= 0, got %d", r.Retention) }
+    }
+    if c.LockDir != "" {
+        if _, err := NewFileLocker(c.LockDir); err != nil { e.add("lock_dir", "%v", err) }
+    }
+    for i := range c.Blackouts { c.Blackouts[i].validate(e, fmt.Sprintf("blackouts[%d].", i)) }
+    if c.ShutdownGrace < 0 { e.add("shutdown_grace_in_seconds", "expect >= 0, got %d", c.ShutdownGrace) }
+
+    if len(c.Jobs) == 0 {
+        c.JobConfig.validate(e, "", c.StateFile != "")
+        if len(c.After) > 0 { e.add("after", "needs a list of jobs") }
+        return
+    }
+
+    // the fields of the single top level job make no sense along with jobs
+    top, typ := reflect.ValueOf(c.JobConfig), reflect.TypeOf(c.JobConfig)
+    for i := 0; i < typ.NumField(); i++ {
+        tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+        if tag != "log_file" && !top.Field(i).IsZero() { e.add(tag, "not allowed at the top level along with jobs") }
+    }
+
+    names := make(map[string]int)
+    for i := range c.Jobs {
+        path := fmt.Sprintf("jobs[%d].", i)
+        name := c.Jobs[i].Name
+        if name == "" { name = fmt.Sprintf("job%d", i) }
+        if j, ok := names[name]; ok { e.add(path + "name", "%q already used by jobs[%d]", name, j) }
+        names[name] = i
+        c.Jobs[i].validate(e, path, c.StateFile != "")
+    }
+    checkDeps(e, c.Jobs, names)
+}
+
+// checkFile reports a file which can't be created, if not empty.
+func checkFile(e *ConfigError, field, path string) {
+    if path == "" { return }
+    if fi, err := os.Stat(path); err == nil {
+        if fi.IsDir() { e.add(field, "%s is a directory", path) }
+        return
+    }
+    if fi, err := os.Stat(filepath.Dir(path)); err != nil || !fi.IsDir() {
+        e.add(field, "no directory %s", filepath.Dir(path))
+    }
+}
+
+// validate the job, path prefixes the field names.
+func (c *JobConfig) validate(e *ConfigError, path string, stateFile bool) {
+    if c.StartTime != "" {
+        if _, _, _, err := c.ParseHourMinSec(c.StartTime); err != nil { e.add(path + "start_time", "%q: %v", c.StartTime, err) }
+    }
+    switch iv := c.Interval; {
+    case iv < 0:
+        e.add(path + "interval_in_seconds", "expect > 0, got %d", iv)
+    case iv > 86400 && iv % 86400 != 0:
+        e.add(path + "interval_in_seconds", "%d is over a day but not a whole number of days", iv)
+    }
+    if c.Cron != "" {
+        if cron, err := schedule.ParseCron(c.Cron); err != nil {
+            e.add(path + "cron", "%v", err)
+        } else if cron.Next(time.Now()).IsZero() {
+            e.add(path + "cron", "%q never fires", c.Cron)
+        }
+    }
+    if c.Timezone != "" {
+        if _, err := time.LoadLocation(c.Timezone); err != nil { e.add(path + "timezone", "%v", err) }
+    }
+    checkFile(e, path + "log_file", c.LogFile)
+
+    if len(c.Command) > 0 && c.Command[0] == "" { e.add(path + "command", "empty program") }
+    for i, kv := range c.Env {
+        if !strings.Contains(kv, "=") { e.add(fmt.Sprintf("%senv[%d]", path, i), "expect KEY=VALUE, got %q", kv) }
+    }
+    if c.Dir != "" {
+        if fi, err := os.Stat(c.Dir); err != nil || !fi.IsDir() { e.add(path + "dir", "no directory %s", c.Dir) }
+    }
+    if c.Timeout < 0 { e.add(path + "timeout_in_seconds", "expect >= 0, got %d", c.Timeout) }
+    if c.KillGrace < 0 { e.add(path + "kill_grace_in_seconds", "expect >= 0, got %d", c.KillGrace) }
+    if l := c.Limits; l != nil {
+        if !LimitsSupported { e.add(path + "limits", "supported on linux only") }
+        if l.CPU < 0 { e.add(path + "limits.cpu_in_seconds", "expect >= 0, got %d", l.CPU) }
+        if l.Memory < 0 { e.add(path + "limits.memory_in_mb", "expect >= 0, got %d", l.Memory) }
+        if l.OpenFiles < 0 { e.add(path + "limits.open_files", "expect >= 0, got %d", l.OpenFiles) }
+    }
+
+    if _, err := ParseOverlap(c.Overlap); err != nil { e.add(path + "overlap", "%v", err) }
+    if catchUp, err := ParseCatchUp(c.CatchUp); err != nil {
+        e.add(path + "catch_up", "%v", err)
+    } else if catchUp != CatchUpSkip && !stateFile {
+        e.add(path + "catch_up", "%q needs a state_file", catchUp)
+    }
+    if _, err := ParseBlackoutPolicy(c.Blackout); err != nil { e.add(path + "blackout", "%v", err) }
+    if c.Splay < 0 { e.add(path + "splay_in_seconds", "expect >= 0, got %d", c.Splay) }
+    if _, err := ParseSplayMode(c.SplayMode); err != nil {
+        e.add(path + "splay_mode", "%v", err)
+    } else if c.SplayMode != "" && c.Splay <= 0 {
+        e.add(path + "splay_mode", "needs splay_in_seconds")
+    }
+    if len(c.After) > 0 {
+        for _, f := range []struct{ name string; set bool }{{"start_time", c.StartTime != ""},
+            {"interval_in_seconds", c.Interval > 0}, {"cron", c.Cron != ""}, {"catch_up", c.CatchUp != ""},
+            {"splay_in_seconds", c.Splay > 0}, {"blackout", c.Blackout != ""}} {
+            if f.set { e.add(path + f.name, "not allowed along with after, the job runs after its upstream jobs") }
+        }
+    }
+
+    if r := c.Retry; r != nil {
+        if r.MaxAttempts < 0 { e.add(path + "retry.max_attempts", "expect >= 0, got %d", r.MaxAttempts) }
+        if r.InitialBackoff < 0 { e.add(path + "retry.initial_backoff_in_seconds", "expect >= 0, got %d", r.InitialBackoff) }
+        if r.Multiplier < 0 { e.add(path + "retry.multiplier", "expect >= 0, got %v", r.Multiplier) }
+        if r.MaxBackoff < 0 { e.add(path + "retry.max_backoff_in_seconds", "expect >= 0, got %d", r.MaxBackoff) }
+        if r.Jitter < 0 || r.Jitter > 1 { e.add(path + "retry.jitter", "expect in [0, 1], got %v", r.Jitter) }
+    }
+}
@@ -0,0 +1,154 @@
+// Real and fake clocks of the schedulers
+//
+package schedule
+
+import (
+    "sort"
+    "sync"
+    "time"
+)
+
+// A Clock tells the time and waits for it, so that the schedulers can run on
+// a FakeClock, e.g. to go through a day or a DST change in no time.
+type Clock interface {
+    Now() time.Time
+    After(d time.Duration) <-chan time.Time
+    Sleep(d time.Duration)
+    NewTicker(d time.Duration) Ticker
+}
+
+// A Ticker is a time.Ticker of a Clock.
+type Ticker interface {
+    C() <-chan time.Time
+    Stop()
+}
+
+// Real is the Clock of package time.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A FakeClock only moves when told to, by Advance or Set, firing the timers
+// and tickers due meanwhile in time order.  Its times have no monotonic clock
+// reading, so a Scheduler never sees a clock jump on it.
+type FakeClock struct {
+    mu      sync.Mutex
+    now     time.Time
+    waiters []*waiter
+    added   *sync.Cond // broadcast on each new waiter
+}
+
+// a waiter is a pending After, Sleep or Ticker of a FakeClock
+type waiter struct {
+    at     time.Time
+    period time.Duration // of a ticker, 0 for a timer
+    c      chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to t.
+func NewFakeClock(t time.Time) *FakeClock {
+    c := &FakeClock{now: t.Round(0)}
+    c.added = sync.NewCond(&c.mu)
+    return c
+}
+
+func (c *FakeClock) Now() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+    return c.wait(d, 0).c
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+    <-c.After(d)
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+    if d <= 0 { panic("schedule: non-positive interval for FakeClock.NewTicker") }
+    return &fakeTicker{c, c.wait(d, d)}
+}
+
+func (c *FakeClock) wait(d, period time.Duration) *waiter {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    w := &waiter{at: c.now.Add(d), period: period, c: make(chan time.Time, 1)}
+    if d <= 0 && period == 0 {
+        w.c <- c.now
+        return w
+    }
+    c.waiters = append(c.waiters, w)
+    c.added.Broadcast()
+    return w
+}
+
+func (c *FakeClock) remove(w *waiter) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for i, v := range c.waiters {
+        if v == w {
+            c.waiters = append(c.waiters[:i], c.waiters[i + 1:]...)
+            return
+        }
+    }
+}
+
+// Advance the clock by d, see Set.
+func (c *FakeClock) Advance(d time.Duration) {
+    c.Set(c.Now().Add(d))
+}
+
+// Set the clock to t, firing in time order the timers and ticks due until t,
+// each one with the clock set to its due time.  A t before now just sets the
+// clock back.  The go routines woken see the clock at t once Set returns, so
+// advance by steps to let them wait again in between.
+func (c *FakeClock) Set(t time.Time) {
+    t = t.Round(0)
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for {
+        sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+        if len(c.waiters) == 0 || c.waiters[0].at.After(t) { break }
+        w := c.waiters[0]
+        if w.at.After(c.now) { c.now = w.at }
+        select {
+        case w.c <- c.now:
+        default: // a ticker drops the ticks of a slow receiver
+        }
+        if w.period > 0 {
+            w.at = w.at.Add(w.period)
+        } else {
+            c.waiters = c.waiters[1:]
+        }
+    }
+    c.now = t
+}
+
+// BlockUntil waits until at least n timers and tickers are pending, e.g. for
+// the go routines under test to wait on the clock before advancing it.
+func (c *FakeClock) BlockUntil(n int) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for len(c.waiters) < n { c.added.Wait() }
+}
+
+type fakeTicker struct {
+    clock *FakeClock
+    w     *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t *fakeTicker) Stop()               { t.clock.remove(t.w) }
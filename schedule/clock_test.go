@@ -0,0 +1,90 @@
+package schedule
+
+import (
+    "testing"
+    "time"
+)
+
+func TestFakeClockAfter(t *testing.T) {
+    start := mustParse(t, time.UTC, "2026-10-18 12:00:00 UTC")
+    clock := NewFakeClock(start)
+    late, early := clock.After(2 * time.Second), clock.After(time.Second)
+    select {
+    case <-early:
+        t.Fatal("fired before the clock moved")
+    default:
+    }
+
+    clock.Advance(3 * time.Second)
+    if at := receive(t, early); !at.Equal(start.Add(time.Second)) { t.Fatalf("early fired at %v", at) }
+    if at := receive(t, late); !at.Equal(start.Add(2 * time.Second)) { t.Fatalf("late fired at %v", at) }
+    if now := clock.Now(); !now.Equal(start.Add(3 * time.Second)) { t.Fatalf("now is %v", now) }
+
+    // a non-positive duration fires at once
+    if at := receive(t, clock.After(0)); !at.Equal(clock.Now()) { t.Fatalf("fired at %v", at) }
+}
+
+func TestPeriodicWorkerOn(t *testing.T) {
+    clock := NewFakeClock(mustParse(t, time.UTC, "2026-10-18 23:59:00 UTC"))
+    var worked []time.Time
+    done, quit := PeriodicWorkerOn(clock, 30 * time.Second, func(idx *int) {
+        *idx++
+        worked = append(worked, clock.Now())
+    })
+
+    for i := 1; i <= 3; i++ {
+        clock.BlockUntil(1)
+        clock.Advance(30 * time.Second)
+        select {
+        case n := <-done:
+            if n != i { t.Fatalf("got %d works, want %d", n, i) }
+        case <-time.After(5 * time.Second):
+            t.Fatal("timed out")
+        }
+    }
+    quit <- struct{}{}
+    if _, ok := <-done; ok { t.Fatal("times not closed") }
+
+    want := times(t, time.UTC, "2026-10-18 23:59:30 UTC", "2026-10-19 00:00:00 UTC", "2026-10-19 00:00:30 UTC")
+    checkFires(t, worked, want)
+}
+
+// CrontabOn waits by slices of DefaultMaxSleep until the next xx:x0.
+func TestCrontabOn(t *testing.T) {
+    clock := NewFakeClock(mustParse(t, time.UTC, "2026-10-18 23:53:00 UTC"))
+    works := 0
+    started, worker := CrontabOn(clock, 10, func() { works++ })
+
+    for d := time.Duration(0); d < 7 * time.Minute; d += DefaultMaxSleep {
+        clock.BlockUntil(1)
+        clock.Advance(DefaultMaxSleep)
+    }
+    want := mustParse(t, time.UTC, "2026-10-19 00:00:00 UTC")
+    if at := receive(t, started); !at.Equal(want) { t.Fatalf("started at %v, want %v", at, want) }
+    select {
+    case <-worker:
+    case <-time.After(5 * time.Second):
+        t.Fatal("timed out")
+    }
+    if works != 1 { t.Fatalf("got %d works, want 1", works) }
+
+    clock.BlockUntil(1)
+    clock.Set(want.Add(10 * time.Minute))
+    select {
+    case <-worker:
+    case <-time.After(5 * time.Second):
+        t.Fatal("timed out")
+    }
+    if works != 2 { t.Fatalf("got %d works, want 2", works) }
+}
+
+func TestCrontabOnRange(t *testing.T) {
+    for _, n := range []int{0, -1, 61} {
+        func() {
+            defer func() {
+                if recover() == nil { t.Errorf("n = %d: expect a panic", n) }
+            }()
+            CrontabOn(NewFakeClock(time.Time{}), n, func() {})
+        }()
+    }
+}
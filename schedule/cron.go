@@ -0,0 +1,181 @@
+// Cron expressions: compute the fire times of a crontab-like spec
+//
+package schedule
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// A Schedule computes the fire times of a job.
+type Schedule interface {
+    // Next returns the first fire time strictly after t, or the zero time if
+    // the schedule never fires again.
+    Next(t time.Time) time.Time
+}
+
+// Cron is a Schedule parsed from a cron expression by ParseCron.
+type Cron struct {
+    spec                                    string
+    second, minute, hour, dom, month, dow   uint64
+    domStar, dowStar                        bool
+}
+
+// the range and value names of a cron field
+type cronField struct {
+    name     string
+    min, max int
+    names    []string // names[i] stands for min + i
+}
+
+var (
+    secondField = cronField{"second", 0, 59, nil}
+    minuteField = cronField{"minute", 0, 59, nil}
+    hourField   = cronField{"hour", 0, 23, nil}
+    domField    = cronField{"day of month", 1, 31, nil}
+    monthField  = cronField{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+    dowField    = cronField{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+var cronMacros = map[string]string{
+    "@yearly":   "0 0 1 1 *",
+    "@annually": "0 0 1 1 *",
+    "@monthly":  "0 0 1 * *",
+    "@weekly":   "0 0 * * 0",
+    "@daily":    "0 0 * * *",
+    "@midnight": "0 0 * * *",
+    "@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard 5 field cron expression
+//    minute hour day-of-month month day-of-week
+// or a 6 field one with a leading second field.  Each field is a comma
+// separated list of `*`, `n` or `n-m`, optionally followed by a `/step`.
+// Months and days of week may be given by their english 3 letter names, and
+// both 0 and 7 stand for sunday.  The macros @yearly (@annually), @monthly,
+// @weekly, @daily (@midnight) and @hourly are supported as well.  As in cron,
+// if both day of month and day of week are restricted, i.e. neither starts
+// with `*`, a day matching either one fires.
+func ParseCron(spec string) (*Cron, error) {
+    expr := strings.TrimSpace(spec)
+    if strings.HasPrefix(expr, "@") {
+        macro, ok := cronMacros[strings.ToLower(expr)]
+        if !ok { return nil, fmt.Errorf("cron %q: unknown macro", spec) }
+        expr = macro
+    }
+
+    fields := strings.Fields(expr)
+    switch len(fields) {
+    case 5:
+        fields = append([]string{"0"}, fields...)
+    case 6:
+    default:
+        return nil, fmt.Errorf("cron %q: expect 5 or 6 fields, got %d", spec, len(fields))
+    }
+
+    c, err := &Cron{spec: spec}, error(nil)
+    bits := []*uint64{&c.second, &c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+    for i, f := range []cronField{secondField, minuteField, hourField, domField, monthField, dowField} {
+        if *bits[i], err = f.parse(fields[i]); err != nil {
+            return nil, fmt.Errorf("cron %q: %v", spec, err)
+        }
+    }
+    c.domStar, c.dowStar = unrestricted(fields[3]), unrestricted(fields[5])
+    if c.dow & (1 << 7) != 0 { c.dow |= 1 } // 7 is sunday too
+    return c, nil
+}
+
+// unrestricted tells if a day field starts with `*` or `?`, e.g. `*/2`, which
+// doesn't restrict the days of the other day field, as in Vixie cron.
+func unrestricted(field string) bool {
+    return strings.HasPrefix(field, "*") || strings.HasPrefix(field, "?")
+}
+
+// parse a comma separated list into a bit set of the allowed values
+func (f cronField) parse(s string) (uint64, error) {
+    var bits uint64
+    for _, item := range strings.Split(s, ",") {
+        rng, step := item, 1
+        if i := strings.Index(item, "/"); i >= 0 {
+            n, err := strconv.Atoi(item[i+1:])
+            if err != nil || n <= 0 { return 0, fmt.Errorf("%s: invalid step in %q", f.name, item) }
+            rng, step = item[:i], n
+        }
+
+        lo, hi := f.min, f.max
+        switch i := strings.Index(rng, "-"); {
+        case rng == "*" || rng == "?":
+        case i > 0:
+            var err error
+            if lo, err = f.value(rng[:i]); err != nil { return 0, err }
+            if hi, err = f.value(rng[i+1:]); err != nil { return 0, err }
+            if lo > hi { return 0, fmt.Errorf("%s: invalid range %q", f.name, rng) }
+        default:
+            var err error
+            if lo, err = f.value(rng); err != nil { return 0, err }
+            if step == 1 { hi = lo } // `n/step` means n-max/step
+        }
+
+        for v := lo; v <= hi; v += step { bits |= 1 << uint(v) }
+    }
+    return bits, nil
+}
+
+// value of a number or a name in the field
+func (f cronField) value(s string) (int, error) {
+    for i, name := range f.names {
+        if strings.EqualFold(s, name) { return f.min + i, nil }
+    }
+    v, err := strconv.Atoi(s)
+    if err != nil { return 0, fmt.Errorf("%s: invalid value %q", f.name, s) }
+    if v < f.min || v > f.max { return 0, fmt.Errorf("%s: %d out of range [%d, %d]", f.name, v, f.min, f.max) }
+    return v, nil
+}
+
+func (c *Cron) String() string { return c.spec }
+
+// the cron day matching rule
+func (c *Cron) matchDay(t time.Time) bool {
+    dom, dow := c.dom & (1 << uint(t.Day())) != 0, c.dow & (1 << uint(t.Weekday())) != 0
+    if c.domStar || c.dowStar { return dom && dow }
+    return dom || dow
+}
+
+// cronHorizon bounds the search of Next, the longest gap of a cron expression
+// firing at all is 8 years (e.g. the 29th of february, across 2100).
+const cronHorizon = 30 * 366
+
+// Next returns the first fire time strictly after t, in the location of t.
+// It walks the calendar day by day, so the result does not depend on the
+// length of the days around a DST change, see Date for the skipped and
+// repeated local times.
+func (c *Cron) Next(t time.Time) time.Time {
+    loc := t.Location()
+    y, m, d := t.Date()
+    for i := 0; i < cronHorizon; i++ {
+        day := time.Date(y, m, d + i, 0, 0, 0, 0, loc)
+        if c.month & (1 << uint(day.Month())) == 0 || !c.matchDay(day) { continue }
+        if next := c.nextInDay(day, t); !next.IsZero() { return next }
+    }
+    return time.Time{}
+}
+
+// first fire time of day which is after t, zero if none
+func (c *Cron) nextInDay(day, t time.Time) time.Time {
+    y, m, d := day.Date()
+    h0 := 0
+    if ty, tm, td := t.Date(); ty == y && tm == m && td == d { h0 = t.Hour() }
+    for h := h0; h < 24; h++ {
+        if c.hour & (1 << uint(h)) == 0 { continue }
+        for mi := 0; mi < 60; mi++ {
+            if c.minute & (1 << uint(mi)) == 0 { continue }
+            for s := 0; s < 60; s++ {
+                if c.second & (1 << uint(s)) == 0 { continue }
+                if next := Date(y, m, d, h, mi, s, day.Location()); next.After(t) { return next }
+            }
+        }
+    }
+    return time.Time{}
+}
@@ -0,0 +1,37 @@
+// Package schedule holds the shared schedulers of the examples.
+//
+// Go crontab implementation on the wall clock
+//
+package schedule
+
+import (
+    "fmt"
+    "time"
+)
+
+// Crontab calls work every n minutes (0 < n <= 60) aligned to the hour, i.e.
+// at xx:00, xx:10, ... for n = 10, by a drift-free Scheduler.  The first fire
+// time is sent on the returned started channel, and a signal on worker after
+// each completed work.  It panics if n is out of range.
+func Crontab(n int, work func()) (started <-chan time.Time, worker <-chan struct{}) {
+    return CrontabOn(Real, n, work)
+}
+
+// CrontabOn is Crontab on the given clock.
+func CrontabOn(clock Clock, n int, work func()) (started <-chan time.Time, worker <-chan struct{}) {
+    if n <= 0 || n > 60 { panic(fmt.Sprintf("schedule: crontab every %d minutes, expect 0 < n <= 60", n)) }
+    cstart, cwork := make(chan time.Time, 1), make(chan struct{})
+    cron, err := ParseCron(fmt.Sprintf("*/%d * * * *", n))
+    if err != nil { panic(err) }
+
+    s := &Scheduler{Schedule: cron, Clock: clock}
+    go s.Run(nil, func(ts time.Time) {
+        select {
+        case cstart <- ts: // trigger first launch at xx:[0-5]0
+        default:
+        }
+        work()
+        cwork <- struct{}{}
+    })
+    return cstart, cwork
+}
@@ -0,0 +1,65 @@
+// Interval schedules on the wall clock
+//
+package schedule
+
+import (
+    "time"
+)
+
+// Every is a Schedule firing at the start time of day Hour:Minute:Second,
+// then by Interval, computed on the calendar of the location of the given
+// time:
+//    an Interval shorter than a day fires at the start time plus or minus any
+//    multiple of Interval of wall-clock time, on a chain going on across the
+//    days since the start time of 1970-01-01, e.g. 08:30 by 1h fires at every
+//    xx:30, and 00:00 by 7h at 00:00, 07:00, 14:00, 21:00, then 04:00 ...;
+//    an Interval of a day or more counts whole calendar days, rounded down,
+//    e.g. 03:00 by 48h fires at 03:00 every other day.
+// So the fire times keep to the wall clock across DST changes, see Date for
+// the skipped and repeated local times.
+type Every struct {
+    Hour, Minute, Second int
+    Interval             time.Duration
+}
+
+const day = 24 * time.Hour
+
+// epochDay numbers the calendar days, so that every n days is stable.
+func epochDay(y int, mo time.Month, d int) int64 {
+    return time.Date(y, mo, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+// wallClock returns the wall-clock time of t as if it were in UTC, since the
+// epoch, so that it goes on evenly across DST changes.
+func wallClock(t time.Time) time.Duration {
+    y, mo, d := t.Date()
+    return time.Duration(time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), 0, time.UTC).Unix()) * time.Second
+}
+
+// Next returns the first fire time strictly after t, in the location of t.
+func (e Every) Next(t time.Time) time.Time {
+    loc, iv := t.Location(), e.Interval
+    if iv <= 0 { iv = day }
+    start := time.Duration(e.Hour) * time.Hour + time.Duration(e.Minute) * time.Minute + time.Duration(e.Second) * time.Second
+    y, mo, d := t.Date()
+
+    if iv >= day {
+        n := int64(iv / day)
+        for i := 0; int64(i) <= n; i++ {
+            if (epochDay(y, mo, d + i) % n + n) % n != 0 { continue }
+            if next := Date(y, mo, d + i, e.Hour, e.Minute, e.Second, loc); next.After(t) { return next }
+        }
+        return time.Time{}
+    }
+
+    // the latest fire of the chain start + k*iv not after t on the wall clock,
+    // then the first one after t, as a skipped or repeated wall-clock time may
+    // map before t
+    c := wallClock(t) - start
+    k := c / iv
+    if c < 0 && c % iv != 0 { k-- }
+    for off := start + k * iv; ; off += iv {
+        w := time.Unix(0, 0).UTC().Add(off)
+        if next := Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), loc); next.After(t) { return next }
+    }
+}
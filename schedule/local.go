@@ -0,0 +1,34 @@
+// Local wall-clock times across DST changes
+//
+package schedule
+
+import (
+    "time"
+)
+
+// Date returns the instant of the local wall-clock time h:m:s on y-mo-d in
+// loc, with a defined behavior around DST changes:
+//    a skipped local time (in the gap of a spring forward) is the first
+//    instant after the gap, e.g. 02:30 is 03:00 when 02:00 jumps to 03:00;
+//    a repeated local time (in the overlap of a fall back) is its first
+//    occurrence, the one with the earlier offset.
+func Date(y int, mo time.Month, d, h, m, s int, loc *time.Location) time.Time {
+    t := time.Date(y, mo, d, h, m, s, 0, loc)
+    start, end := t.ZoneBounds()
+
+    if t.Hour() != h || t.Minute() != m || t.Second() != s {
+        // skipped: t is pushed after the gap, so its zone starts right after
+        // the gap, or before the gap, so its zone ends right before it
+        if t.Hour() * 3600 + t.Minute() * 60 + t.Second() > h * 3600 + m * 60 + s { return start }
+        return end
+    }
+    if start.IsZero() { return t }
+
+    // repeated: the same wall-clock time may exist before the zone of t
+    _, off := t.Zone()
+    _, prevOff := start.Add(-time.Nanosecond).Zone()
+    if prevOff > off {
+        if u := t.Add(-time.Duration(prevOff - off) * time.Second); u.Before(start) { return u }
+    }
+    return t
+}
@@ -0,0 +1,20 @@
+// Schedules shifted by a fixed delay
+//
+package schedule
+
+import (
+    "time"
+)
+
+// Offset is a Schedule firing Delay after each fire time of Schedule, e.g. to
+// splay the fire times of hosts sharing a schedule.
+type Offset struct {
+    Schedule Schedule
+    Delay    time.Duration
+}
+
+func (o Offset) Next(t time.Time) time.Time {
+    next := o.Schedule.Next(t.Add(-o.Delay))
+    if next.IsZero() { return next }
+    return next.Add(o.Delay)
+}
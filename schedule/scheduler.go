@@ -0,0 +1,86 @@
+// A drift-free scheduler on the wall clock
+//
+package schedule
+
+import (
+    "errors"
+    "time"
+)
+
+// ErrNeverFires is returned by Scheduler.Run once its schedule is exhausted.
+var ErrNeverFires = errors.New("schedule never fires again")
+
+const (
+    DefaultMaxSleep  = 10 * time.Second // see Scheduler.MaxSleep
+    DefaultTolerance = time.Second      // see Scheduler.Tolerance
+)
+
+// A Scheduler fires at the times of a Schedule.  Unlike a time.Ticker, each
+// next fire time is computed from the wall clock, and waited for in slices of
+// at most MaxSleep, so the fire times never drift: a suspend/resume, a long GC
+// pause or a clock set by NTP just realign it onto the schedule.
+type Scheduler struct {
+    Schedule  Schedule
+    Location  *time.Location // of the schedule's calendar, local if nil
+    MaxSleep  time.Duration  // DefaultMaxSleep if <= 0
+    Tolerance time.Duration  // of the wall clock against the monotonic one, DefaultTolerance if <= 0
+    Clock     Clock          // Real if nil
+
+    // OnNext, if not nil, is called with each next fire time before waiting.
+    OnNext func(next time.Time)
+    // OnJump, if not nil, is called when the wall clock jumped by d against
+    // the monotonic clock, e.g. after a suspend or a clock change.
+    OnJump func(d time.Duration)
+}
+
+// Next returns the first fire time after t on the calendar of s.Location.
+func (s *Scheduler) Next(t time.Time) time.Time {
+    loc := s.Location
+    if loc == nil { loc = time.Local }
+    return s.Schedule.Next(t.In(loc))
+}
+
+// Run calls fire at each fire time until quit is closed, then returns nil, or
+// until the schedule never fires again, then returns ErrNeverFires.
+//
+// A forward jump of the clock over a fire time fires it once, late, and the
+// following ones are computed from the clock again.  A backward jump never
+// fires a time again, the next one is computed after the latest fired one.
+func (s *Scheduler) Run(quit <-chan struct{}, fire func(at time.Time)) error {
+    maxSleep, tolerance := s.MaxSleep, s.Tolerance
+    if maxSleep <= 0 { maxSleep = DefaultMaxSleep }
+    if tolerance <= 0 { tolerance = DefaultTolerance }
+    clock := s.Clock
+    if clock == nil { clock = Real }
+
+    var last time.Time
+    for {
+        from := clock.Now()
+        if last.After(from) { from = last }
+        next := s.Next(from)
+        if next.IsZero() { return ErrNeverFires }
+        if s.OnNext != nil { s.OnNext(next) }
+
+        for {
+            // next has no monotonic reading, so this is wall-clock time
+            now := clock.Now()
+            wait := next.Sub(now)
+            if wait <= 0 { break }
+            if wait > maxSleep { wait = maxSleep }
+
+            select {
+            case <-quit:
+                return nil
+            case <-clock.After(wait):
+            }
+
+            woke := clock.Now()
+            if d := woke.Round(0).Sub(now.Round(0)) - woke.Sub(now); (d > tolerance || d < -tolerance) && s.OnJump != nil {
+                s.OnJump(d)
+            }
+        }
+
+        fire(next)
+        last = next
+    }
+}
@@ -0,0 +1,174 @@
+package schedule
+
+import (
+    "testing"
+    "time"
+    _ "time/tzdata" // America/New_York, whatever the host has
+)
+
+// receive from c, or fail after a while.
+func receive(t *testing.T, c <-chan time.Time) time.Time {
+    t.Helper()
+    select {
+    case v := <-c:
+        return v
+    case <-time.After(5 * time.Second):
+        t.Fatal("timed out")
+    }
+    return time.Time{}
+}
+
+// fires runs a Scheduler of sched on the calendar of loc on a FakeClock set to
+// from, and returns its first n fire times, the clock set to each one in turn.
+func fires(t *testing.T, sched Schedule, loc *time.Location, from time.Time, n int) []time.Time {
+    t.Helper()
+    clock := NewFakeClock(from)
+    nexts, fired := make(chan time.Time, 1), make(chan time.Time, 1)
+    s := &Scheduler{Schedule: sched, Location: loc, Clock: clock, MaxSleep: 48 * time.Hour,
+        OnNext: func(next time.Time) { nexts <- next }}
+    quit := make(chan struct{})
+    defer close(quit)
+    go s.Run(quit, func(at time.Time) { fired <- at })
+
+    var got []time.Time
+    for i := 0; i < n; i++ {
+        next := receive(t, nexts)
+        clock.BlockUntil(1)
+        clock.Set(next)
+        got = append(got, receive(t, fired))
+        if now := clock.Now(); !now.Equal(next) { t.Fatalf("fired at %v, the clock is at %v", next, now) }
+    }
+    return got
+}
+
+// times parses the times in loc, as "2006-01-02 15:04:05 MST" so that the
+// abbreviation tells the repeated local times apart.
+func times(t *testing.T, loc *time.Location, values ...string) []time.Time {
+    t.Helper()
+    var ts []time.Time
+    for _, v := range values {
+        ts = append(ts, mustParse(t, loc, v))
+    }
+    return ts
+}
+
+func mustParse(t *testing.T, loc *time.Location, v string) time.Time {
+    t.Helper()
+    ts, err := time.ParseInLocation("2006-01-02 15:04:05 MST", v, loc)
+    if err != nil { t.Fatal(err) }
+    return ts
+}
+
+func newYork(t *testing.T) *time.Location {
+    t.Helper()
+    loc, err := time.LoadLocation("America/New_York")
+    if err != nil { t.Fatal(err) }
+    return loc
+}
+
+func mustCron(t *testing.T, spec string) *Cron {
+    t.Helper()
+    c, err := ParseCron(spec)
+    if err != nil { t.Fatal(err) }
+    return c
+}
+
+func checkFires(t *testing.T, got, want []time.Time) {
+    t.Helper()
+    for i := range want {
+        if i >= len(got) || !got[i].Equal(want[i]) {
+            t.Fatalf("fire %d: got %v, want %v", i, got, want)
+        }
+    }
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestSchedulerMidnight(t *testing.T) {
+    utc := time.UTC
+    from := mustParse(t, utc, "2026-12-31 23:57:30 UTC")
+    tests := []struct {
+        name  string
+        sched Schedule
+        want  []string
+    }{
+        {"cron every minute", mustCron(t, "* * * * *"),
+            []string{"2026-12-31 23:58:00 UTC", "2026-12-31 23:59:00 UTC", "2027-01-01 00:00:00 UTC", "2027-01-01 00:01:00 UTC"}},
+        {"cron daily", mustCron(t, "@daily"),
+            []string{"2027-01-01 00:00:00 UTC", "2027-01-02 00:00:00 UTC"}},
+        {"cron at 23:59", mustCron(t, "59 23 * * *"),
+            []string{"2026-12-31 23:59:00 UTC", "2027-01-01 23:59:00 UTC"}},
+        {"every 1h from 08:00", Every{Hour: 8, Interval: time.Hour},
+            []string{"2027-01-01 00:00:00 UTC", "2027-01-01 01:00:00 UTC", "2027-01-01 02:00:00 UTC"}},
+        {"every 20m from 23:10", Every{Hour: 23, Minute: 10, Interval: 20 * time.Minute},
+            []string{"2027-01-01 00:10:00 UTC", "2027-01-01 00:30:00 UTC", "2027-01-01 00:50:00 UTC"}},
+        // 2027-01-01 00:00 is 499656h, 3h after a fire, since 1970-01-01 00:00
+        {"every 7h from 00:00", Every{Interval: 7 * time.Hour},
+            []string{"2027-01-01 04:00:00 UTC", "2027-01-01 11:00:00 UTC", "2027-01-01 18:00:00 UTC", "2027-01-02 01:00:00 UTC", "2027-01-02 08:00:00 UTC"}},
+        {"every 24h from 00:00", Every{Interval: 24 * time.Hour},
+            []string{"2027-01-01 00:00:00 UTC", "2027-01-02 00:00:00 UTC"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            want := times(t, utc, tt.want...)
+            checkFires(t, fires(t, tt.sched, utc, from, len(want)), want)
+        })
+    }
+}
+
+// On 2026-03-08 02:00 EST jumps to 03:00 EDT in New York, on 2026-11-01 02:00
+// EDT falls back to 01:00 EST.
+func TestSchedulerDST(t *testing.T) {
+    ny := newYork(t)
+    tests := []struct {
+        name  string
+        sched Schedule
+        from  string
+        want  []string
+    }{
+        {"spring forward, cron hourly", mustCron(t, "0 * * * *"), "2026-03-08 00:30:00 EST",
+            []string{"2026-03-08 01:00:00 EST", "2026-03-08 03:00:00 EDT", "2026-03-08 04:00:00 EDT"}},
+        {"spring forward, cron in the gap", mustCron(t, "30 2 * * *"), "2026-03-07 00:00:00 EST",
+            []string{"2026-03-07 02:30:00 EST", "2026-03-08 03:00:00 EDT", "2026-03-09 02:30:00 EDT"}},
+        {"spring forward, every 30m", Every{Minute: 15, Interval: 30 * time.Minute}, "2026-03-08 01:00:00 EST",
+            []string{"2026-03-08 01:15:00 EST", "2026-03-08 01:45:00 EST", "2026-03-08 03:00:00 EDT", "2026-03-08 03:15:00 EDT"}},
+        {"spring forward, every day", Every{Hour: 2, Minute: 30, Interval: 24 * time.Hour}, "2026-03-07 12:00:00 EST",
+            []string{"2026-03-08 03:00:00 EDT", "2026-03-09 02:30:00 EDT"}},
+        {"fall back, cron hourly", mustCron(t, "0 * * * *"), "2026-11-01 00:30:00 EDT",
+            []string{"2026-11-01 01:00:00 EDT", "2026-11-01 02:00:00 EST", "2026-11-01 03:00:00 EST"}},
+        {"fall back, cron in the overlap", mustCron(t, "30 1 * * *"), "2026-10-31 00:00:00 EDT",
+            []string{"2026-10-31 01:30:00 EDT", "2026-11-01 01:30:00 EDT", "2026-11-02 01:30:00 EST"}},
+        {"fall back, every 30m", Every{Interval: 30 * time.Minute}, "2026-11-01 00:45:00 EDT",
+            []string{"2026-11-01 01:00:00 EDT", "2026-11-01 01:30:00 EDT", "2026-11-01 02:00:00 EST", "2026-11-01 02:30:00 EST"}},
+        {"fall back, every day", Every{Hour: 1, Minute: 30, Interval: 24 * time.Hour}, "2026-10-31 12:00:00 EDT",
+            []string{"2026-11-01 01:30:00 EDT", "2026-11-02 01:30:00 EST"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            want := times(t, ny, tt.want...)
+            checkFires(t, fires(t, tt.sched, ny, mustParse(t, ny, tt.from), len(want)), want)
+        })
+    }
+}
+
+// The wait is sliced by MaxSleep, which the fire times don't depend on.
+func TestSchedulerMaxSleep(t *testing.T) {
+    clock := NewFakeClock(mustParse(t, time.UTC, "2026-10-18 23:59:58 UTC"))
+    fired := make(chan time.Time, 1)
+    s := &Scheduler{Schedule: mustCron(t, "@daily"), Location: time.UTC, Clock: clock, MaxSleep: time.Second}
+    quit := make(chan struct{})
+    defer close(quit)
+    go s.Run(quit, func(at time.Time) { fired <- at })
+
+    for i := 0; i < 2; i++ {
+        clock.BlockUntil(1)
+        select {
+        case at := <-fired:
+            t.Fatalf("fired early at %v", at)
+        default:
+        }
+        clock.Advance(time.Second)
+    }
+    want := mustParse(t, time.UTC, "2026-10-19 00:00:00 UTC")
+    if at := receive(t, fired); !at.Equal(want) { t.Fatalf("fired at %v, want %v", at, want) }
+}
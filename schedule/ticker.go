@@ -0,0 +1,34 @@
+// Go Concurrency Pattern: Ticker
+//
+package schedule
+
+import (
+    "time"
+)
+
+// PeriodicWorker calls work with an increasing index by interval, and sends the
+// number of completed works on times.  Send on quit to stop it, times is closed
+// then.
+func PeriodicWorker(interval time.Duration, work func(idx *int)) (<-chan int, chan<- struct{}) {
+    return PeriodicWorkerOn(Real, interval, work)
+}
+
+// PeriodicWorkerOn is PeriodicWorker on the given clock.
+func PeriodicWorkerOn(clock Clock, interval time.Duration, work func(idx *int)) (<-chan int, chan<- struct{}) {
+    times, quit := make(chan int), make(chan struct{})
+    ticker, idx := clock.NewTicker(interval), 0
+    go func() {
+        defer close(times)   // stop the main routine's wating
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C(): // trigger by ticker
+                work(&idx)
+                times <- idx
+            case <-quit:       // stop the closure go routine
+                return
+            }
+        }
+    }()
+    return times, quit
+}
@@ -0,0 +1,64 @@
+// Recurring windows of calendar time, e.g. maintenance windows
+//
+package schedule
+
+import (
+    "time"
+)
+
+// A Window is a recurring span of wall-clock time: from the time of day From
+// until To, on the given weekdays and dates only, on the calendar of Location.
+// From and To count the seconds since midnight; a To not after From spans
+// midnight, and belongs to the day it starts on; both 0 is the whole day.
+type Window struct {
+    From, To int
+    Weekdays []time.Weekday    // any day if empty
+    Dates    map[string]bool   // as 2006-01-02, any day if nil
+    Location *time.Location    // local if nil
+}
+
+// Contains tells whether t is within the window.
+func (w *Window) Contains(t time.Time) bool {
+    _, ok := w.start(t)
+    return ok
+}
+
+// start returns the day t is within the window on, if it is.
+func (w *Window) start(t time.Time) (time.Time, bool) {
+    loc := w.Location
+    if loc == nil { loc = time.Local }
+    t = t.In(loc)
+    tod := t.Hour() * 3600 + t.Minute() * 60 + t.Second()
+    day := t
+    switch {
+    case w.From == 0 && w.To == 0:
+    case w.From < w.To:
+        if tod < w.From || tod >= w.To { return t, false }
+    case tod >= w.From:
+    case tod < w.To:
+        y, mo, d := t.Date()
+        day = time.Date(y, mo, d - 1, 12, 0, 0, 0, loc)
+    default:
+        return t, false
+    }
+    return day, w.onDay(day)
+}
+
+func (w *Window) onDay(day time.Time) bool {
+    if w.Dates != nil && !w.Dates[day.Format("2006-01-02")] { return false }
+    if len(w.Weekdays) == 0 { return true }
+    for _, wd := range w.Weekdays {
+        if wd == day.Weekday() { return true }
+    }
+    return false
+}
+
+// End returns the close of the window t is within, zero if it's not.
+func (w *Window) End(t time.Time) time.Time {
+    day, ok := w.start(t)
+    if !ok { return time.Time{} }
+    y, mo, d := day.Date()
+    if w.From == 0 && w.To == 0 { return Date(y, mo, d + 1, 0, 0, 0, day.Location()) }
+    if w.To <= w.From { d++ }
+    return Date(y, mo, d, w.To / 3600, w.To / 60 % 60, w.To % 60, day.Location())
+}
@@ -0,0 +1,32 @@
+// Package sigworker implements Go singal process: a go routine waits for the
+// SIGINT/SIGTERM signal and notifies the main go routine.
+//
+package sigworker
+
+import (
+    "os"
+    "os/signal"
+    "syscall"
+)
+
+// Start registers a channel for sigs, SIGINT and SIGTERM if none given, and
+// starts a go routine waiting on it.  The first received signal is relayed on
+// the returned channel; later ones get their default behavior again, so a
+// second Ctrl-C still kills a process which is slow to exit.
+func Start(sigs ...os.Signal) <-chan os.Signal {
+    if len(sigs) == 0 { sigs = []os.Signal{syscall.SIGINT, syscall.SIGTERM} }
+
+    // signal.Notify registers the given channel to
+    // receive notifications of the spec signals
+    csig := make(chan os.Signal, 1)
+    signal.Notify(csig, sigs...)
+
+    done := make(chan os.Signal, 1)
+    go func() {
+        // wait the SIGINT/SIGTERM signal
+        sig := <-csig
+        signal.Stop(csig)
+        done <- sig
+    }()
+    return done
+}